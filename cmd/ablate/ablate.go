@@ -0,0 +1,172 @@
+package ablate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/spf13/cobra"
+)
+
+// Cmd defines the apoco ablate command.
+var Cmd = &cobra.Command{
+	Use:   "ablate",
+	Short: "Analyze the importance of the features of post-correction models",
+}
+
+var flags = struct {
+	parameter, model, typ string
+	nocr                  int
+	groups, json          bool
+}{}
+
+func init() {
+	// Ablate flags
+	Cmd.PersistentFlags().StringVarP(&flags.parameter, "parameter", "p", "config.toml",
+		"set the path to the configuration file")
+	Cmd.PersistentFlags().StringVarP(&flags.model, "model", "M", "",
+		"set the model path (overwrites the setting in the configuration file)")
+	Cmd.PersistentFlags().StringVarP(&flags.typ, "type", "t", "rr",
+		"set the type of the model (rr or dm)")
+	Cmd.PersistentFlags().IntVarP(&flags.nocr, "nocr", "n", 0,
+		"set the number of parallel OCRs (overwrites the setting in the configuration file)")
+	Cmd.PersistentFlags().BoolVarP(&flags.groups, "groups", "G", false,
+		"group the features by their prefix (Candidate, OCR, Ranking, ...)")
+	Cmd.PersistentFlags().BoolVarP(&flags.json, "json", "J", false, "set json output")
+	// Subcommands
+	Cmd.AddCommand(retrainCmd, permuteCmd)
+}
+
+// unit is a feature (or a group of features) with the columns of its
+// feature values.
+type unit struct {
+	name string
+	cols []int
+}
+
+// units returns the units of the given feature columns (see
+// apoco.FeatureSet.Names).  If groups is set, the features are
+// grouped by their prefix.
+func units(cols []string, groups bool) []unit {
+	var ret []unit
+	pos := make(map[string]int)
+	for i, col := range cols {
+		name := col
+		if j := strings.LastIndex(name, "/"); j != -1 {
+			name = name[:j]
+		}
+		if groups {
+			name = prefix(name)
+		}
+		if _, ok := pos[name]; !ok {
+			pos[name] = len(ret)
+			ret = append(ret, unit{name: name})
+		}
+		ret[pos[name]].cols = append(ret[pos[name]].cols, i)
+	}
+	return ret
+}
+
+// prefix returns the first camel case word (or acronym) of the given
+// feature name (e.g. OCR for OCRLevenshteinDist).
+func prefix(name string) string {
+	if i := strings.Index(name, "("); i != -1 {
+		name = name[:i]
+	}
+	rs := []rune(name)
+	if len(rs) < 2 {
+		return name
+	}
+	i := 1
+	if unicode.IsUpper(rs[1]) {
+		for i < len(rs) && unicode.IsUpper(rs[i]) && (i+1 == len(rs) || unicode.IsUpper(rs[i+1])) {
+			i++
+		}
+	} else {
+		for i < len(rs) && !unicode.IsUpper(rs[i]) {
+			i++
+		}
+	}
+	return string(rs[:i])
+}
+
+// without returns all columns in [0,n) that are not in the unit.
+func (u unit) without(n int) []int {
+	skip := make(map[int]bool, len(u.cols))
+	for _, col := range u.cols {
+		skip[col] = true
+	}
+	var ret []int
+	for i := 0; i < n; i++ {
+		if !skip[i] {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// result holds the evaluation of the model without (or with
+// permuted) features.  The differences are relative to the baseline.
+type result struct {
+	Feature                         string
+	Columns                         int
+	Precision, Recall, F1, Accuracy float64
+	DiffF1, DiffAccuracy            float64
+}
+
+func makeResult(name string, cols int, c ml.Confusion) result {
+	return result{
+		Feature:   name,
+		Columns:   cols,
+		Precision: c.Precision(),
+		Recall:    c.Recall(),
+		F1:        c.F1(),
+		Accuracy:  c.Accuracy(),
+	}
+}
+
+// report holds the results of an analysis.
+type report struct {
+	Type      string
+	Nocr      int
+	Instances int
+	Baseline  result
+	Results   []result
+}
+
+func (r *report) add(res result) {
+	res.DiffF1 = res.F1 - r.Baseline.F1
+	res.DiffAccuracy = res.Accuracy - r.Baseline.Accuracy
+	r.Results = append(r.Results, res)
+}
+
+// write writes the report.  The results are sorted by their f1
+// differences, so that the most important features come first.
+func (r *report) write(out io.Writer) error {
+	sort.SliceStable(r.Results, func(i, j int) bool {
+		return r.Results[i].DiffF1 < r.Results[j].DiffF1
+	})
+	if flags.json {
+		return json.NewEncoder(out).Encode(r)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "# %s/%d instances=%d\n", r.Type, r.Nocr, r.Instances)
+	fmt.Fprintf(w, "#feature\tcols\tpr\tre\tf1\tacc\tΔf1\tΔacc\n")
+	for _, res := range append([]result{r.Baseline}, r.Results...) {
+		fmt.Fprintf(w, "%s\t%d\t%f\t%f\t%f\t%f\t%+f\t%+f\n", res.Feature, res.Columns,
+			res.Precision, res.Recall, res.F1, res.Accuracy, res.DiffF1, res.DiffAccuracy)
+	}
+	return w.Flush()
+}
+
+func chk(err error) {
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
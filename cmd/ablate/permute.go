@@ -0,0 +1,104 @@
+package ablate
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/mat"
+)
+
+// permuteCmd defines the apoco ablate permute command.
+var permuteCmd = &cobra.Command{
+	Use:   "permute [CSV...]",
+	Short: "Calculate the permutation importance of the features of a model",
+	Long: `
+Evaluates the existing model of the given type on the instances of
+the evaluation CSV files (see apoco csv).  Reads from stdin, if no
+CSV files are given.  For each feature (or feature group), the
+values of its columns are randomly permuted over all instances and
+the model is evaluated again.  The mean differences over all repeats
+give the permutation importance of the feature.`,
+	Run: permuteRun,
+}
+
+var permuteFlags = struct {
+	repeats int
+	seed    int64
+}{}
+
+func init() {
+	permuteCmd.Flags().IntVarP(&permuteFlags.repeats, "repeats", "r", 5,
+		"set the number of permutations of each feature")
+	permuteCmd.Flags().Int64VarP(&permuteFlags.seed, "seed", "s", 1,
+		"set the seed for the permutations")
+}
+
+func permuteRun(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(flags.parameter)
+	chk(err)
+
+	internal.UpdateInConfig(&c.Model, flags.model)
+	internal.UpdateInConfig(&c.Nocr, flags.nocr)
+
+	m, err := internal.ReadModel(c.Model, c.LM, false)
+	chk(err)
+	lr, fs, err := m.Get(flags.typ, c.Nocr)
+	chk(err)
+	cols := fs.Names(m.Models[flags.typ][c.Nocr].Features, flags.typ, c.Nocr)
+	var is internal.Instances
+	if len(args) == 0 {
+		chk(is.Read(os.Stdin))
+	}
+	for _, arg := range args {
+		in, err := os.Open(arg)
+		chk(err)
+		err = is.Read(in)
+		in.Close()
+		chk(err)
+	}
+	if is.Len() == 0 || is.Cols() != len(cols) {
+		chk(fmt.Errorf("ablate permute %s/%d: expected %d features for %d instances",
+			flags.typ, c.Nocr, len(cols), is.Len()))
+	}
+	x, y := is.Mat()
+	r := report{Type: flags.typ, Nocr: c.Nocr, Instances: is.Len()}
+	r.Baseline = makeResult("all", len(cols), ml.Evaluate(lr, x, y, 0.5))
+	rnd := rand.New(rand.NewSource(permuteFlags.seed))
+	for _, u := range units(cols, flags.groups) {
+		var sum result
+		for i := 0; i < permuteFlags.repeats; i++ {
+			res := makeResult("", 0, ml.Evaluate(lr, permute(x, u.cols, rnd), y, 0.5))
+			sum.Precision += res.Precision
+			sum.Recall += res.Recall
+			sum.F1 += res.F1
+			sum.Accuracy += res.Accuracy
+		}
+		n := float64(permuteFlags.repeats)
+		r.add(result{
+			Feature:   u.name,
+			Columns:   len(u.cols),
+			Precision: sum.Precision / n,
+			Recall:    sum.Recall / n,
+			F1:        sum.F1 / n,
+			Accuracy:  sum.Accuracy / n,
+		})
+	}
+	chk(r.write(os.Stdout))
+}
+
+// permute returns a copy of x with the rows of the given columns
+// permuted.  All columns are permuted using the same permutation.
+func permute(x *mat.Dense, cols []int, r *rand.Rand) *mat.Dense {
+	ret := mat.DenseCopyOf(x)
+	rows, _ := x.Dims()
+	for i, j := range r.Perm(rows) {
+		for _, col := range cols {
+			ret.Set(i, col, x.At(j, col))
+		}
+	}
+	return ret
+}
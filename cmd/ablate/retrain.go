@@ -0,0 +1,150 @@
+package ablate
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/spf13/cobra"
+)
+
+// retrainCmd defines the apoco ablate retrain command.
+var retrainCmd = &cobra.Command{
+	Use:   "retrain [DIRS...]",
+	Short: "Retrain models with each feature removed",
+	Long: `
+Splits the documents in DIRS into a training and a held-out set and
+trains the model of the given type (rr or dm) with all configured
+features and with each feature (or feature group) removed.  Reports
+the metrics of each model on the held-out documents together with
+the differences to the model with all features.  The dm models use
+the rankings of a re-ranking model that is trained with all features
+on the training documents.`,
+	Run: retrainRun,
+}
+
+var retrainFlags = struct {
+	extensions []string
+	filter     string
+	test       float64
+	seed       int64
+	cache      bool
+}{}
+
+func init() {
+	retrainCmd.Flags().StringSliceVarP(&retrainFlags.extensions, "extensions", "e", []string{".xml"},
+		"set the input file extensions")
+	retrainCmd.Flags().StringVarP(&retrainFlags.filter, "filter", "f", "",
+		"set the filter for the dm training (overwrites the setting in the configuration file)")
+	retrainCmd.Flags().Float64VarP(&retrainFlags.test, "test", "T", .2,
+		"set the fraction of held-out documents")
+	retrainCmd.Flags().Int64VarP(&retrainFlags.seed, "seed", "s", 1,
+		"set the seed for the split of the documents")
+	retrainCmd.Flags().BoolVarP(&retrainFlags.cache, "cache", "c", false,
+		"enable caching of profiles (overwrites the setting in the configuration file)")
+}
+
+func retrainRun(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(flags.parameter)
+	chk(err)
+
+	internal.UpdateInConfig(&c.Model, flags.model)
+	internal.UpdateInConfig(&c.Nocr, flags.nocr)
+	internal.UpdateInConfig(&c.Cache, retrainFlags.cache)
+	internal.UpdateInConfig(&c.DM.Filter, retrainFlags.filter)
+
+	train, test, err := internal.SplitDocuments(args, retrainFlags.test,
+		rand.New(rand.NewSource(retrainFlags.seed)))
+	chk(err)
+	m, err := internal.ReadModel(c.Model, c.LM, true)
+	chk(err)
+	xtrain, xtest, tc, err := instances(c, m, train, test)
+	chk(err)
+	fs, err := apoco.NewFeatureSet(tc.Features...)
+	chk(err)
+	cols := fs.Names(tc.Features, flags.typ, c.Nocr)
+	r := report{Type: flags.typ, Nocr: c.Nocr, Instances: xtrain.Len()}
+	r.Baseline = makeResult("all", len(cols), retrain(tc, xtrain, xtest))
+	for _, u := range units(cols, flags.groups) {
+		keep := u.without(len(cols))
+		if len(keep) == 0 {
+			continue
+		}
+		res := retrain(tc, xtrain.Columns(keep), xtest.Columns(keep))
+		r.add(makeResult("-"+u.name, len(u.cols), res))
+	}
+	chk(r.write(os.Stdout))
+}
+
+// retrain trains a new model on the training instances and evaluates
+// it on the test instances.
+func retrain(tc internal.TrainingConfig, train, test *internal.Instances) ml.Confusion {
+	lr := train.Fit(tc)
+	x, y := test.Mat()
+	return ml.Evaluate(lr, x, y, 0.5)
+}
+
+// instances returns the training and test instances for the model
+// type together with the according training configuration.
+func instances(c *internal.Config, m *internal.Model, train, test []string) (*internal.Instances, *internal.Instances, internal.TrainingConfig, error) {
+	fail := func(err error) (*internal.Instances, *internal.Instances, internal.TrainingConfig, error) {
+		return nil, nil, internal.TrainingConfig{}, fmt.Errorf("ablate %s/%d: %v", flags.typ, c.Nocr, err)
+	}
+	rrfs, err := apoco.NewFeatureSet(c.RR.Features...)
+	if err != nil {
+		return fail(err)
+	}
+	rrtrain, err := collect(c, m, train, rrfs, internal.RRGT)
+	if err != nil {
+		return fail(err)
+	}
+	switch flags.typ {
+	case "rr":
+		rrtest, err := collect(c, m, test, rrfs, internal.RRGT)
+		if err != nil {
+			return fail(err)
+		}
+		return rrtrain, rrtest, c.RR, nil
+	case "dm":
+		rank := apoco.ConnectRankings(rrtrain.Fit(c.RR), rrfs, c.Nocr)
+		dmfs, err := apoco.NewFeatureSet(c.DM.Features...)
+		if err != nil {
+			return fail(err)
+		}
+		dmtrain, err := collect(c, m, train, dmfs, internal.DMGT(c.DM.Filter), rank)
+		if err != nil {
+			return fail(err)
+		}
+		// Evaluate on all held-out tokens.
+		dmtest, err := collect(c, m, test, dmfs, internal.DMGT(internal.Cautious), rank)
+		if err != nil {
+			return fail(err)
+		}
+		return dmtrain, dmtest, c.DM.TrainingConfig, nil
+	default:
+		return fail(fmt.Errorf("bad type"))
+	}
+}
+
+func collect(c *internal.Config, m *internal.Model, dirs []string, fs apoco.FeatureSet,
+	gt func(apoco.T) (float64, bool), fns ...apoco.StreamFunc) (*internal.Instances, error) {
+	p := internal.Piper{
+		Exts:     retrainFlags.extensions,
+		Dirs:     dirs,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+	}
+	var is internal.Instances
+	fns = append(append(internal.CandidateStreams(c, m), fns...), is.Collect(fs, c.Nocr, gt))
+	if err := p.Pipe(context.Background(), fns...); err != nil {
+		return nil, err
+	}
+	if is.Len() == 0 {
+		return nil, fmt.Errorf("no instances")
+	}
+	return &is, nil
+}
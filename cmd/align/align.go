@@ -3,12 +3,14 @@ package align
 import (
 	"encoding/xml"
 	"fmt"
+	"image"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 
 	"git.sr.ht/~flobar/apoco/cmd/internal"
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
@@ -16,13 +18,15 @@ import (
 	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/node"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
+	"git.sr.ht/~flobar/lev"
 	"github.com/antchfx/xmlquery"
 	"github.com/spf13/cobra"
 )
 
 var flags = struct {
-	ifgs      []string
-	ofg, mets string
+	ifgs             []string
+	ofg, mets, pages string
+	subset, report   bool
 }{}
 
 // Cmd defines the apoco align command.
@@ -36,44 +40,75 @@ func init() {
 	Cmd.Flags().StringVarP(&flags.ofg, "out-file-grp", "O", "", "set output file group of alignments")
 	Cmd.Flags().StringVarP(&flags.mets, "mets", "m", "mets.xml", "set path to mets file")
 	Cmd.Flags().StringSliceVarP(&flags.ifgs, "input-file-grp", "I", nil, "set input file groups")
+	Cmd.Flags().StringVar(&flags.pages, "page-id", "",
+		"only align the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
+	Cmd.Flags().BoolVarP(&flags.subset, "subset", "s", false,
+		"only align the pages that are present in all input file groups")
+	Cmd.Flags().BoolVarP(&flags.report, "report", "r", false,
+		"print the alignment scores of the lines and pages (without an output file group nothing is written)")
 }
 
 func run(_ *cobra.Command, args []string) {
-	chk(alignFiles(flags.mets, flags.ofg, flags.ifgs))
+	var report func(string, []lineScore)
+	if flags.report {
+		report = printReport
+	}
+	chk(alignFiles(flags.mets, flags.ofg, flags.pages, flags.ifgs, flags.subset, report))
 }
 
 type file struct {
 	path, id string
 }
 
-func getPaths(m mets.METS, ifgs []string) ([][]file, error) {
-	// Append sorted list of files in the filegroups.
-	var tmp [][]file
-	for _, ifg := range ifgs {
-		flocats := m.FindFlocats(ifg)
-		files := mkfiles(m, flocats)
-		tmp = append(tmp, files)
-	}
-	// Check that we have the same number of files for each input
-	// file group.
-	var n int
-	for i, paths := range tmp {
-		if i == 0 {
-			n = len(paths)
-		}
-		if len(paths) != n {
-			return nil, fmt.Errorf("cannot align files")
+// getPaths returns the files of the input file groups grouped by
+// their pages (in the order of the physical structMap).  Files are
+// matched using the pages that reference them in the physical
+// structMap.  If subset is true, only the pages present in all file
+// groups are returned and the skipped pages are reported on stderr.
+// Otherwise an error is returned for missing pages.
+func getPaths(m mets.METS, pages, ifgs []string, subset bool) ([][]file, error) {
+	byPage := make(map[string][]file)
+	for i, ifg := range ifgs {
+		for _, f := range mkfiles(m, m.FindFlocats(ifg, pages...)) {
+			page, ok := m.PageForFileID(f.id)
+			if !ok {
+				return nil, fmt.Errorf("cannot align files: missing page for file %s", f.id)
+			}
+			if _, ok := byPage[page]; !ok {
+				byPage[page] = make([]file, len(ifgs))
+			}
+			if byPage[page][i].id != "" {
+				return nil, fmt.Errorf("cannot align files: multiple files for page %s in file group %s", page, ifg)
+			}
+			byPage[page][i] = f
 		}
 	}
-	// Transpose the temporary array and return it.
-	ret := make([][]file, n)
-	for i := range ret {
-		ret[i] = make([]file, len(ifgs))
-	}
-	for i := range tmp {
-		for j := range tmp[i] {
-			ret[j][i] = tmp[i][j]
+	var ret [][]file
+	var missing []string
+	for _, page := range m.Pages() {
+		files, ok := byPage[page]
+		if !ok {
+			continue
+		}
+		var fgs []string
+		for i := range files {
+			if files[i].id == "" {
+				fgs = append(fgs, ifgs[i])
+			}
 		}
+		if len(fgs) > 0 {
+			if subset {
+				log.Printf("warning: skipping page %s: missing file(s) in file group(s) %s",
+					page, strings.Join(fgs, ", "))
+			}
+			missing = append(missing, page)
+			continue
+		}
+		ret = append(ret, files)
+	}
+	if len(missing) > 0 && !subset {
+		return nil, fmt.Errorf("cannot align files: missing files for page(s) %s (use --subset)",
+			strings.Join(missing, ", "))
 	}
 	return ret, nil
 }
@@ -95,43 +130,95 @@ func mkfiles(m mets.METS, flocats []*xmlquery.Node) []file {
 
 const agent = "ocrd/cis/apoco-align " + internal.Version
 
-func alignFiles(mpath, ofg string, ifgs []string) error {
-	m, fg, err := readMETS(mpath, ofg)
+// alignFiles aligns the files of the input file groups and writes
+// them into the output file group.  If report is not nil, it is
+// called with the page id and the line scores of each aligned page.
+// If no output file group is given, only the report is generated.
+func alignFiles(mpath, ofg, spec string, ifgs []string, subset bool, report func(string, []lineScore)) error {
+	if ofg == "" && report == nil {
+		return fmt.Errorf("missing output file group")
+	}
+	m, err := mets.Open(mpath)
 	if err != nil {
 		return err
 	}
-	files, err := getPaths(m, ifgs)
+	pages, err := m.PageIDs(spec)
 	if err != nil {
 		return err
 	}
+	files, err := getPaths(m, pages, ifgs, subset)
+	if err != nil {
+		return err
+	}
+	var fg *xmlquery.Node
+	if ofg != "" {
+		if fg, err = outputFileGrp(m, ofg, pages); err != nil {
+			return err
+		}
+	}
 	for i := range files {
 		apoco.Log("align files: %v", files[i])
-		doc, err := alignFile(files[i])
+		doc, scores, err := alignFile(files[i])
 		if err != nil {
 			return err
 		}
+		if report != nil {
+			page, _ := m.PageForFileID(files[i][0].id)
+			report(page, scores)
+		}
+		if ofg == "" {
+			continue
+		}
 		opath := addFileToMETS(m, fg, ofg, files[i][0])
 		if err := writeToWS(doc, mpath, ofg, opath); err != nil {
 			return err
 		}
 	}
+	if ofg == "" {
+		return nil
+	}
 	if err := m.AddAgent(internal.PStep, agent); err != nil {
 		return err
 	}
 	return m.Write()
 }
 
-func alignFile(files []file) (*xmlquery.Node, error) {
+// lineScore holds the alignment score of a primary line.
+type lineScore struct {
+	id    string
+	score align.Score
+}
+
+func alignFile(files []file) (*xmlquery.Node, []lineScore, error) {
 	lines, err := alignLines(files)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	scores := make([]lineScore, len(lines))
 	for i := range lines {
-		if err := alignWords(lines[i]); err != nil {
-			return nil, err
+		score, err := alignWords(lines[i])
+		if err != nil {
+			return nil, nil, err
 		}
+		scores[i] = lineScore{id: lines[i][0].id(), score: score}
 	}
-	return root(lines[0][0].node), nil
+	return root(lines[0][0].node), scores, nil
+}
+
+// printReport prints the line scores and the accumulated score of the
+// given page.
+func printReport(page string, scores []lineScore) {
+	var sum align.Score
+	for _, s := range scores {
+		fmt.Printf("line %s %s %s\n", page, s.id, fmtScore(s.score))
+		sum.Add(s.score)
+	}
+	fmt.Printf("page %s %s\n", page, fmtScore(sum))
+}
+
+func fmtScore(s align.Score) string {
+	return fmt.Sprintf("tokens=%d empty=%d merged=%d agreement=%.4f",
+		s.Tokens, s.Empty, s.Merged, s.Agreement())
 }
 
 func alignLines(files []file) ([][]region, error) {
@@ -146,44 +233,174 @@ func alignLines(files []file) ([][]region, error) {
 	}
 	// Read lines from documents nodes.
 	lines := make([][]region, len(files))
-	var n int
 	for i, node := range docs {
 		tmp, err := getLines(node)
 		if err != nil {
 			return nil, err
 		}
-		if i == 0 {
-			n = len(tmp)
+		lines[i] = tmp
+	}
+	// Match the lines of the secondary documents with the lines of
+	// the primary document.
+	ret := make([][]region, len(lines[0]))
+	for i := range ret {
+		ret[i] = make([]region, len(files))
+		ret[i][0] = lines[0][i]
+	}
+	for j := 1; j < len(lines); j++ {
+		matches := matchLines(lines[0], lines[j])
+		for i := range ret {
+			ret[i][j] = concatLines(lines[j], matches[i])
+		}
+	}
+	return ret, nil
+}
+
+// Minimal overlap and similarity of two lines to be matched.
+const (
+	minLineOverlap    = .5
+	minLineSimilarity = .4
+)
+
+// matchLines matches the secondary lines with the primary lines and
+// returns the indices of the matched secondary lines for each primary
+// line.  If all lines have coordinates, each secondary line is
+// matched with the primary line with the largest overlap of their
+// bounding boxes.  Multiple secondary lines matched with the same
+// primary line are ordered from left to right.  If coordinates are
+// missing, lines with the same ids are matched in order.  Otherwise
+// the lines are matched using their textual similarity.  Since the
+// similarity is relative to the longer line, parts of split lines are
+// in general only matched using their coordinates.
+func matchLines(ps, ss []region) [][]int {
+	ret := make([][]int, len(ps))
+	geom := hasBoundingBoxes(ps) && hasBoundingBoxes(ss)
+	if !geom && sameIDs(ps, ss) {
+		for i := range ret {
+			ret[i] = []int{i}
+		}
+		return ret
+	}
+	var m lev.Mat
+	for j := range ss {
+		best, max := -1, 0.0
+		for i := range ps {
+			var score float64
+			if geom {
+				score = overlap(ps[i].bbox, ss[j].bbox)
+			} else {
+				score = similarity(&m, ps[i].text, ss[j].text)
+			}
+			if score > max {
+				best, max = i, score
+			}
 		}
-		if len(tmp) != n {
-			return nil, fmt.Errorf("cannot align lines")
+		if best == -1 || (geom && max < minLineOverlap) || (!geom && max < minLineSimilarity) {
+			apoco.Log("cannot match line %s", ss[j].id())
+			continue
 		}
-		lines[i] = tmp
+		ret[best] = append(ret[best], j)
 	}
-	// Transpose lines
-	linesT := make([][]region, n)
-	for i := range linesT {
-		linesT[i] = make([]region, len(files))
+	if geom {
+		for i := range ret {
+			sort.SliceStable(ret[i], func(a, b int) bool {
+				return ss[ret[i][a]].bbox.Min.X < ss[ret[i][b]].bbox.Min.X
+			})
+		}
 	}
-	for i := range lines {
-		for j := range lines[i] {
-			linesT[j][i] = lines[i][j]
+	return ret
+}
+
+// concatLines concatenates the given lines into one line.  The
+// resulting line uses the node and the TextEquivs of the first line.
+// If no lines are given, an empty line is returned.
+func concatLines(lines []region, ids []int) region {
+	if len(ids) == 0 {
+		return region{}
+	}
+	if len(ids) == 1 {
+		return lines[ids[0]]
+	}
+	ret := region{
+		node:     lines[ids[0]].node,
+		unicodes: lines[ids[0]].unicodes,
+		bbox:     lines[ids[0]].bbox,
+		hasBBox:  lines[ids[0]].hasBBox,
+	}
+	for i, id := range ids {
+		if i > 0 {
+			ret.text = append(ret.text, ' ')
+		}
+		ret.text = append(ret.text, lines[id].text...)
+		ret.subregions = append(ret.subregions, lines[id].subregions...)
+		ret.bbox = ret.bbox.Union(lines[id].bbox)
+	}
+	return ret
+}
+
+func hasBoundingBoxes(lines []region) bool {
+	for _, line := range lines {
+		if !line.hasBBox {
+			return false
 		}
 	}
-	return linesT, nil
+	return true
 }
 
-func alignWords(lines []region) error {
+func sameIDs(ps, ss []region) bool {
+	if len(ps) != len(ss) {
+		return false
+	}
+	for i := range ps {
+		if ps[i].id() != ss[i].id() {
+			return false
+		}
+	}
+	return true
+}
+
+// overlap returns the area of the intersection of the two boxes
+// relative to the area of the smaller box.  Relating the
+// intersection to the smaller box (and not to the union as IoU does)
+// allows to match parts of split lines.
+func overlap(a, b image.Rectangle) float64 {
+	area := func(r image.Rectangle) int { return r.Dx() * r.Dy() }
+	min := area(a)
+	if area(b) < min {
+		min = area(b)
+	}
+	if min == 0 {
+		return 0
+	}
+	return float64(area(a.Intersect(b))) / float64(min)
+}
+
+// similarity returns the normalized Levenshtein similarity of the two
+// strings.
+func similarity(m *lev.Mat, a, b []rune) float64 {
+	max := len(a)
+	if len(b) > max {
+		max = len(b)
+	}
+	if max == 0 {
+		return 0
+	}
+	return 1 - float64(m.DistanceR(a, b))/float64(max)
+}
+
+// alignWords aligns the words of the secondary lines with the words
+// of the primary line and returns the accumulated score of the
+// alignments.
+func alignWords(lines []region) (align.Score, error) {
+	var score align.Score
 	if len(lines) == 0 {
-		return fmt.Errorf("align words: empty")
+		return score, fmt.Errorf("align words: empty")
 	}
 	lines[0].prepareForAlignment()
 	for i := 1; i < len(lines); i++ {
-		if err := lines[0].alignWith(lines[i]); err != nil {
-			return err
-		}
+		score.Add(lines[0].alignWith(lines[i]))
 	}
-	return nil
+	return score, nil
 }
 
 type region struct {
@@ -191,6 +408,8 @@ type region struct {
 	text       []rune
 	subregions []region
 	unicodes   []*xmlquery.Node
+	bbox       image.Rectangle
+	hasBBox    bool
 }
 
 func getLines(doc *xmlquery.Node) ([]region, error) {
@@ -223,11 +442,14 @@ func mkline(r *xmlquery.Node) (region, error) {
 	if err != nil {
 		return region{}, err
 	}
+	bbox, ok := pagexml.BoundingBox(r)
 	return region{
 		node:       r,
 		text:       []rune(node.Data(node.FirstChild(unicodes[0]))),
 		subregions: words,
 		unicodes:   unicodes,
+		bbox:       bbox,
+		hasBBox:    ok,
 	}, nil
 }
 
@@ -276,19 +498,18 @@ func (r *region) prepareForAlignment() {
 	}
 }
 
-func (r *region) alignWith(o region) error {
-	// Both aligned lines need to have the same ids (to
-	// make sure that we are really aligning the right lines with
-	// each other).
-	if r.id() != o.id() {
-		return fmt.Errorf("cannot align line id %s with line id %s", r.id(), o.id())
-	}
+// alignWith aligns the words of the secondary line o with the words
+// of the primary line r and returns the score of the alignment.
+func (r *region) alignWith(o region) align.Score {
 	// Both vars r and o are supposed to be lines.  Words are
 	// aligned below r's word nodes using r as primary alignment
 	// line.
+	n := len(r.unicodes)       // number of TextEquivs before the alignment
 	pstr, pepos := r.eposMap() // primary line
 	sstr, sepos := o.eposMap() // secondary line
 	pos := align.Do(pstr, sstr)
+	var m lev.Mat
+	score := align.Rate(&m, pos)
 	for i := range pos {
 		// Since we align two regions, len(pos[i]) = 2 is implied.
 		pi, ok := pepos[pos[i][0].E]
@@ -313,9 +534,17 @@ func (r *region) alignWith(o region) error {
 			r.subregions[pi].appendTextEquiv(text, o.subregions[b:si+1]...)
 		}
 	}
+	// Unmatched secondary lines (and secondary lines without words)
+	// do not add any word TextEquivs.  Add empty TextEquivs to keep
+	// the indices of the following OCRs in sync with the line.
+	for i := range r.subregions {
+		for len(r.subregions[i].unicodes) <= n {
+			r.subregions[i].appendTextEquiv("")
+		}
+	}
 	// Append the secondary line to r.
 	r.appendTextEquiv(string(sstr), o)
-	return nil
+	return score
 }
 
 // eposMap concatenates the subregions of a region to a string
@@ -381,8 +610,13 @@ func (r *region) appendEmptyWord() {
 }
 
 func (r *region) appendTextEquiv(text string, others ...region) {
-	sum := 0.0
+	var sum, n float64
 	for _, other := range others {
+		// Unmatched lines do not have any TextEquivs.
+		if len(other.unicodes) == 0 {
+			continue
+		}
+		n++
 		conf, _ := node.LookupAttrAsFloat(other.unicodes[0].Parent, xml.Name{Local: "conf"})
 		sum += conf
 	}
@@ -411,7 +645,7 @@ func (r *region) appendTextEquiv(text string, others ...region) {
 	})
 	node.SetAttr(te, xml.Attr{
 		Name:  xml.Name{Local: "conf"},
-		Value: fmt.Sprintf("%g", sum/float64(len(others))),
+		Value: fmt.Sprintf("%g", avg(sum, n)),
 	})
 	node.SetAttr(te, xml.Attr{
 		Name:  xml.Name{Local: "dataType"},
@@ -424,26 +658,28 @@ func (r *region) appendTextEquiv(text string, others ...region) {
 	node.AppendChild(r.unicodes[0].Parent.Parent, te)
 }
 
-func readMETS(name, ofg string) (mets.METS, *xmlquery.Node, error) {
-	m, err := mets.Open(name)
-	if err != nil {
-		return mets.METS{}, nil, err
+func avg(sum, n float64) float64 {
+	if n == 0 {
+		return 0
 	}
+	return sum / n
+}
+
+func outputFileGrp(m mets.METS, ofg string, pages []string) (*xmlquery.Node, error) {
 	// Check if the given file group already exists and overwrite
 	// it if it already exists.
 	expr := fmt.Sprintf("//*[local-name()='fileGrp'][@USE=%q]", ofg)
 	existing := xmlquery.FindOne(m.Root, expr)
 	if existing != nil {
-		// Delete all children.
-		existing.FirstChild = nil
-		existing.LastChild = nil
-		return m, existing, nil
+		// Delete all children (of the selected pages).
+		m.ClearFileGrp(existing, pages...)
+		return existing, nil
 	}
 	// The given output file group does not yet exist. Add a new
 	// filegroup entry.
 	fileGrps := xmlquery.Find(m.Root, "//*[local-name()='fileGrp']")
 	if len(fileGrps) == 0 {
-		return mets.METS{}, nil, fmt.Errorf("missing file grp in %s", m.Name)
+		return nil, fmt.Errorf("missing file grp in %s", m.Name)
 	}
 	fileGrp := &xmlquery.Node{
 		Data:         "fileGrp",
@@ -455,7 +691,7 @@ func readMETS(name, ofg string) (mets.METS, *xmlquery.Node, error) {
 		Value: ofg,
 	})
 	node.PrependSibling(fileGrps[0], fileGrp)
-	return m, fileGrp, nil
+	return fileGrp, nil
 }
 
 func addFileToMETS(m mets.METS, fg *xmlquery.Node, ofg string, f file) string {
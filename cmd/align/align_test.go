@@ -0,0 +1,244 @@
+package align
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
+	"git.sr.ht/~flobar/lev"
+	"github.com/antchfx/xmlquery"
+)
+
+func mklineFromString(t *testing.T, str string) region {
+	doc, err := xmlquery.Parse(strings.NewReader(str))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	line, err := mkline(xmlquery.FindOne(doc, "//*[local-name()='TextLine']"))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	return line
+}
+
+func TestAlignWordsUnmatchedLine(t *testing.T) {
+	lines := []region{
+		mklineFromString(t, `<TextLine id="l1">
+<Word id="w1"><TextEquiv index="1"><Unicode>Die</Unicode></TextEquiv></Word>
+<Word id="w2"><TextEquiv index="1"><Unicode>Verfassung</Unicode></TextEquiv></Word>
+<TextEquiv index="1"><Unicode>Die Verfassung</Unicode></TextEquiv>
+</TextLine>`),
+		{}, // unmatched line of the second OCR
+		mklineFromString(t, `<TextLine id="l1">
+<Word id="w1"><TextEquiv index="1"><Unicode>Dle</Unicode></TextEquiv></Word>
+<Word id="w2"><TextEquiv index="1"><Unicode>Verfaffung</Unicode></TextEquiv></Word>
+<TextEquiv index="1"><Unicode>Dle Verfaffung</Unicode></TextEquiv>
+</TextLine>`),
+	}
+	if _, err := alignWords(lines); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := [][]string{
+		{"Die", "", "Dle"},
+		{"Verfassung", "", "Verfaffung"},
+	}
+	if len(lines[0].subregions) != len(want) {
+		t.Fatalf("expected %d words; got %d", len(want), len(lines[0].subregions))
+	}
+	for i, w := range lines[0].subregions {
+		unicodes := pagexml.FindUnicodesInRegionSorted(w.node)
+		if len(unicodes) != len(want[i]) {
+			t.Fatalf("expected %d text equivs; got %d", len(want[i]), len(unicodes))
+		}
+		for j := range unicodes {
+			var got string
+			if unicodes[j].FirstChild != nil {
+				got = unicodes[j].FirstChild.Data
+			}
+			if got != want[i][j] {
+				t.Errorf("expected %q at index %d; got %q", want[i][j], j+1, got)
+			}
+		}
+	}
+	if got := len(pagexml.FindUnicodesInRegionSorted(lines[0].node)); got != 3 {
+		t.Errorf("expected 3 line text equivs; got %d", got)
+	}
+}
+
+// mktestline creates a line with the given id and words.  If box is
+// not empty, the line gets the according coordinates.
+func mktestline(t *testing.T, id string, box image.Rectangle, words ...string) region {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<TextLine id=%q>`, id)
+	if !box.Empty() {
+		fmt.Fprintf(&b, `<Coords points="%d,%d %d,%d"/>`, box.Min.X, box.Min.Y, box.Max.X-1, box.Max.Y-1)
+	}
+	for i, word := range words {
+		fmt.Fprintf(&b, `<Word id="%s_w%d"><TextEquiv index="1"><Unicode>%s</Unicode></TextEquiv></Word>`, id, i+1, word)
+	}
+	fmt.Fprintf(&b, `<TextEquiv index="1"><Unicode>%s</Unicode></TextEquiv></TextLine>`, strings.Join(words, " "))
+	return mklineFromString(t, b.String())
+}
+
+func TestOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		a, b image.Rectangle
+		want float64
+	}{
+		{image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), .5},
+		{image.Rect(0, 0, 100, 10), image.Rect(50, 0, 100, 10), 1},
+		{image.Rect(0, 0, 10, 10), image.Rect(20, 0, 30, 10), 0},
+		{image.Rect(0, 0, 10, 10), image.Rectangle{}, 0},
+	} {
+		t.Run(fmt.Sprintf("%v/%v", tc.a, tc.b), func(t *testing.T) {
+			if got := overlap(tc.a, tc.b); got != tc.want {
+				t.Errorf("expected %g; got %g", tc.want, got)
+			}
+			if got := overlap(tc.b, tc.a); got != tc.want {
+				t.Errorf("expected symmetric %g; got %g", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want float64
+	}{
+		{"abcd", "abcd", 1},
+		{"abcd", "abed", .75},
+		{"abcd", "ab", .5},
+		{"abcd", "wxyz", 0},
+		{"", "", 0},
+	} {
+		t.Run(tc.a+"/"+tc.b, func(t *testing.T) {
+			var m lev.Mat
+			if got := similarity(&m, []rune(tc.a), []rune(tc.b)); got != tc.want {
+				t.Errorf("expected %g; got %g", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	var none image.Rectangle
+	l1, l2 := image.Rect(0, 0, 100, 10), image.Rect(0, 20, 100, 30)
+	for _, tc := range []struct {
+		name   string
+		ps, ss []region
+		want   [][]int
+	}{
+		{
+			"coordinates",
+			[]region{mktestline(t, "l1", l1, "Die", "Verfassung"), mktestline(t, "l2", l2, "des", "Landes")},
+			[]region{
+				mktestline(t, "x1", image.Rect(2, 21, 98, 29), "dcs", "Landcs"),
+				mktestline(t, "x2", image.Rect(1, 1, 99, 11), "Dle", "Verfaffung"),
+				mktestline(t, "x3", image.Rect(0, 40, 100, 50), "unmatched"),
+			},
+			[][]int{{1}, {0}},
+		},
+		{
+			"split line",
+			[]region{mktestline(t, "l1", l1, "Die", "Verfassung"), mktestline(t, "l2", l2, "des", "Landes")},
+			[]region{
+				mktestline(t, "x1", image.Rect(30, 0, 100, 10), "Verfaffung"),
+				mktestline(t, "x2", image.Rect(0, 0, 25, 10), "Dle"),
+				mktestline(t, "x3", l2, "des", "Landes"),
+			},
+			[][]int{{1, 0}, {2}},
+		},
+		{
+			"same ids",
+			[]region{mktestline(t, "l1", none, "Die", "Verfassung"), mktestline(t, "l2", none, "des", "Landes")},
+			[]region{mktestline(t, "l1", none, "xxx"), mktestline(t, "l2", none, "yyy")},
+			[][]int{{0}, {1}},
+		},
+		{
+			"text",
+			[]region{mktestline(t, "l1", l1, "Die", "Verfassung"), mktestline(t, "l2", l2, "des", "Landes")},
+			[]region{
+				mktestline(t, "x1", none, "dcs", "Landcs"),
+				mktestline(t, "x2", none, "Dle", "Verfaffung"),
+				mktestline(t, "x3", none, "unmatched", "text"),
+			},
+			[][]int{{1}, {0}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := matchLines(tc.ps, tc.ss)
+			for i := range got {
+				if got[i] == nil {
+					got[i] = []int{}
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConcatLines(t *testing.T) {
+	lines := []region{
+		mktestline(t, "x1", image.Rect(50, 0, 100, 10), "Verfaffung,"),
+		mktestline(t, "x2", image.Rect(0, 0, 40, 12), "Dle"),
+	}
+	if got := concatLines(lines, nil); got.node != nil || len(got.text) != 0 {
+		t.Errorf("expected an empty line; got %s", string(got.text))
+	}
+	if got := concatLines(lines, []int{1}); got.node != lines[1].node {
+		t.Errorf("expected line %s; got %s", lines[1].id(), got.id())
+	}
+	got := concatLines(lines, []int{1, 0})
+	if want := "Dle Verfaffung,"; string(got.text) != want {
+		t.Errorf("expected text %q; got %q", want, string(got.text))
+	}
+	if got.id() != "x2" || len(got.unicodes) != 1 {
+		t.Errorf("expected the node and TextEquivs of x2; got %s", got.id())
+	}
+	if len(got.subregions) != 2 || got.subregions[0].id() != "x2_w1" || got.subregions[1].id() != "x1_w1" {
+		t.Errorf("invalid words of concatenated line")
+	}
+	if want := image.Rect(0, 0, 100, 12); got.bbox != want {
+		t.Errorf("expected bbox %v; got %v", want, got.bbox)
+	}
+}
+
+func TestAlignWordsSplitLine(t *testing.T) {
+	primary := mktestline(t, "l1", image.Rect(0, 0, 100, 10), "Die", "Verfassung", "des")
+	secondary := []region{
+		mktestline(t, "x1", image.Rect(50, 0, 100, 10), "Verfaffung", "dcs"),
+		mktestline(t, "x2", image.Rect(0, 0, 40, 10), "Dle"),
+	}
+	matches := matchLines([]region{primary}, secondary)
+	lines := []region{primary, concatLines(secondary, matches[0])}
+	if _, err := alignWords(lines); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := [][]string{{"Die", "Dle"}, {"Verfassung", "Verfaffung"}, {"des", "dcs"}}
+	if len(lines[0].subregions) != len(want) {
+		t.Fatalf("expected %d words; got %d", len(want), len(lines[0].subregions))
+	}
+	for i, w := range lines[0].subregions {
+		var got []string
+		for _, u := range pagexml.FindUnicodesInRegionSorted(w.node) {
+			got = append(got, u.InnerText())
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("expected %v; got %v", want[i], got)
+		}
+	}
+	var got []string
+	for _, u := range pagexml.FindUnicodesInRegionSorted(lines[0].node) {
+		got = append(got, u.InnerText())
+	}
+	if want := []string{"Die Verfassung des", "Dle Verfaffung dcs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected line %v; got %v", want, got)
+	}
+}
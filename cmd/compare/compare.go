@@ -0,0 +1,267 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"git.sr.ht/~flobar/lev"
+	"github.com/spf13/cobra"
+)
+
+// Cmd defines the apoco compare command.
+var Cmd = &cobra.Command{
+	Use:   "compare A.stoks B.stoks",
+	Short: "Compare the results of two correction runs",
+	Long: `Compare the results of two correction runs.
+
+The stoks of both runs are joined by their file (#name) and ID.
+Tokens that flipped from correct to wrong (and vice versa) after the
+correction are counted and McNemar's test is run on these flips.
+Confidence intervals for the differences (B-A) of the accuracy and
+the char error rate after the correction are calculated using
+bootstrap resampling over the joined tokens.  Finally the most
+frequent flips of both directions are listed.`,
+	Args: cobra.ExactArgs(2),
+	Run:  run,
+}
+
+var flags = struct {
+	resamples, top int
+	seed           int64
+	level          float64
+	json           bool
+}{}
+
+func init() {
+	Cmd.Flags().IntVarP(&flags.resamples, "resamples", "r", 1000,
+		"set the number of bootstrap resamples")
+	Cmd.Flags().IntVarP(&flags.top, "top", "k", 10,
+		"set the number of listed flips for each direction")
+	Cmd.Flags().Int64VarP(&flags.seed, "seed", "s", 1,
+		"set the seed for the bootstrap resampling")
+	Cmd.Flags().Float64VarP(&flags.level, "confidence", "c", .95,
+		"set the confidence level of the bootstrap intervals")
+	Cmd.Flags().BoolVarP(&flags.json, "json", "J", false, "set json output")
+}
+
+func run(_ *cobra.Command, args []string) {
+	a, err := readStoks(args[0])
+	chk(err)
+	b, err := readStoks(args[1])
+	chk(err)
+	c := join(a, b)
+	c.A, c.B = args[0], args[1]
+	if c.Joined == 0 {
+		chk(fmt.Errorf("compare %s %s: no common tokens", c.A, c.B))
+	}
+	c.compare(rand.New(rand.NewSource(flags.seed)))
+	if flags.json {
+		chk(json.NewEncoder(os.Stdout).Encode(c))
+		return
+	}
+	c.write()
+}
+
+// key identifies stoks by their file and ID.
+type key struct {
+	file, id string
+}
+
+// stoks holds the stoks of a correction run in their original order.
+type stoks struct {
+	keys  []key
+	stoks map[key]internal.Stok
+}
+
+func readStoks(name string) (*stoks, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("read stoks %s: %v", name, err)
+	}
+	defer in.Close()
+	ret := &stoks{stoks: make(map[key]internal.Stok)}
+	err = internal.EachStok(in, func(file string, stok internal.Stok) error {
+		k := key{file: file, id: stok.ID}
+		if _, ok := ret.stoks[k]; !ok {
+			ret.keys = append(ret.keys, k)
+		}
+		ret.stoks[k] = stok
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read stoks %s: %v", name, err)
+	}
+	return ret, nil
+}
+
+// pair holds the joined stoks of both runs and their (after
+// correction) char errors.
+type pair struct {
+	a, b       internal.Stok
+	aErr, bErr int
+	chars      int
+}
+
+// flip represents a group of flipped tokens.
+type flip struct {
+	OCR, GT, A, B string
+	Count         int
+}
+
+// comparison holds the results of the comparison.
+type comparison struct {
+	A, B                  string
+	Joined, OnlyA, OnlyB  int
+	AccuracyA, AccuracyB  float64
+	AccuracyDiffCI        [2]float64
+	CERA, CERB            float64
+	CERDiffCI             [2]float64
+	CorrectToWrong        int // Correct in A and wrong in B.
+	WrongToCorrect        int // Wrong in A and correct in B.
+	McNemarChi2, McNemarP float64
+	Regressions, Fixes    []flip
+	pairs                 []pair
+}
+
+func join(a, b *stoks) *comparison {
+	var c comparison
+	var mat lev.Mat
+	for _, k := range a.keys {
+		sb, ok := b.stoks[k]
+		if !ok {
+			c.OnlyA++
+			continue
+		}
+		sa := a.stoks[k]
+		c.pairs = append(c.pairs, pair{
+			a:     sa,
+			b:     sb,
+			aErr:  mat.Distance(after(sa), sa.GT),
+			bErr:  mat.Distance(after(sb), sb.GT),
+			chars: utf8.RuneCountInString(sa.GT),
+		})
+	}
+	c.Joined = len(c.pairs)
+	c.OnlyB = len(b.keys) - c.Joined
+	return &c
+}
+
+// after returns the token after the correction.
+func after(s internal.Stok) string {
+	if !s.Skipped && s.Cor {
+		return s.Sug
+	}
+	return s.OCR
+}
+
+func (c *comparison) compare(r *rand.Rand) {
+	all := make([]int, len(c.pairs))
+	for i := range all {
+		all[i] = i
+	}
+	c.AccuracyA, c.AccuracyB = c.accuracies(all)
+	c.CERA, c.CERB = c.cers(all)
+	lo, hi := ml.Bootstrap(len(c.pairs), flags.resamples, flags.level, r, func(idx []int) float64 {
+		a, b := c.accuracies(idx)
+		return b - a
+	})
+	c.AccuracyDiffCI = [2]float64{lo, hi}
+	lo, hi = ml.Bootstrap(len(c.pairs), flags.resamples, flags.level, r, func(idx []int) float64 {
+		a, b := c.cers(idx)
+		return b - a
+	})
+	c.CERDiffCI = [2]float64{lo, hi}
+	regressions := make(map[flip]int)
+	fixes := make(map[flip]int)
+	for _, p := range c.pairs {
+		f := flip{OCR: p.a.OCR, GT: p.a.GT, A: after(p.a), B: after(p.b)}
+		switch errA, errB := p.a.ErrAfter(), p.b.ErrAfter(); {
+		case !errA && errB:
+			c.CorrectToWrong++
+			regressions[f]++
+		case errA && !errB:
+			c.WrongToCorrect++
+			fixes[f]++
+		}
+	}
+	c.McNemarChi2, c.McNemarP = ml.McNemar(c.CorrectToWrong, c.WrongToCorrect)
+	c.Regressions = topFlips(regressions, flags.top)
+	c.Fixes = topFlips(fixes, flags.top)
+}
+
+func (c *comparison) accuracies(idx []int) (float64, float64) {
+	var a, b int
+	for _, i := range idx {
+		if !c.pairs[i].a.ErrAfter() {
+			a++
+		}
+		if !c.pairs[i].b.ErrAfter() {
+			b++
+		}
+	}
+	return ml.Ratio(a, len(idx)), ml.Ratio(b, len(idx))
+}
+
+func (c *comparison) cers(idx []int) (float64, float64) {
+	var a, b, n int
+	for _, i := range idx {
+		a += c.pairs[i].aErr
+		b += c.pairs[i].bErr
+		n += c.pairs[i].chars
+	}
+	return ml.Ratio(a, n), ml.Ratio(b, n)
+}
+
+func topFlips(m map[flip]int, k int) []flip {
+	ret := make([]flip, 0, len(m))
+	for f, n := range m {
+		f.Count = n
+		ret = append(ret, f)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].GT < ret[j].GT
+	})
+	if k >= 0 && len(ret) > k {
+		ret = ret[:k]
+	}
+	return ret
+}
+
+func (c *comparison) write() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "Name A\t%s\n", c.A)
+	fmt.Fprintf(w, "Name B\t%s\n", c.B)
+	fmt.Fprintf(w, "Tokens (joined/only A/only B)\t%d/%d/%d\n", c.Joined, c.OnlyA, c.OnlyB)
+	fmt.Fprintf(w, "Accuracy (A/B)\t%g/%g\n", c.AccuracyA, c.AccuracyB)
+	fmt.Fprintf(w, "Accuracy B-A (%g%% CI)\t%g [%g, %g]\n", flags.level*100,
+		c.AccuracyB-c.AccuracyA, c.AccuracyDiffCI[0], c.AccuracyDiffCI[1])
+	fmt.Fprintf(w, "Char error rate (A/B)\t%g/%g\n", c.CERA, c.CERB)
+	fmt.Fprintf(w, "Char error rate B-A (%g%% CI)\t%g [%g, %g]\n", flags.level*100,
+		c.CERB-c.CERA, c.CERDiffCI[0], c.CERDiffCI[1])
+	fmt.Fprintf(w, "Flips (correct→wrong/wrong→correct)\t%d/%d\n", c.CorrectToWrong, c.WrongToCorrect)
+	fmt.Fprintf(w, "McNemar (chi2/p-value)\t%g/%g\n", c.McNemarChi2, c.McNemarP)
+	for _, f := range c.Regressions {
+		fmt.Fprintf(w, "correct→wrong\t%d ocr=%s gt=%s a=%s b=%s\n", f.Count, f.OCR, f.GT, f.A, f.B)
+	}
+	for _, f := range c.Fixes {
+		fmt.Fprintf(w, "wrong→correct\t%d ocr=%s gt=%s a=%s b=%s\n", f.Count, f.OCR, f.GT, f.A, f.B)
+	}
+}
+
+func chk(err error) {
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package correct
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/node"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
+	"github.com/antchfx/xmlquery"
+	"github.com/spf13/cobra"
+)
+
+var applyFlags = struct {
+	ifgs                          []string
+	ofg, mets, stoks, keep, pages string
+	threshold                     float64
+	drop                          bool
+}{}
+
+// ApplyCmd defines the apoco apply command.
+var ApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Re-apply corrections using a different confidence threshold",
+	Long: `Re-apply corrections using a different confidence threshold.
+
+The correction decisions are either read from a stoks file (see
+--stoks) or from the dataTypeDetails of already corrected page xml
+files in the input file groups.  If a stoks file is used, the
+document names of the stoks must match the input file groups.  If no
+output file group is given, the updated stoks are written to stdout.`,
+	Run: runApply,
+}
+
+var revertFlags = struct {
+	ifgs                   []string
+	ofg, mets, keep, pages string
+	drop                   bool
+}{}
+
+// RevertCmd defines the apoco revert command.
+var RevertCmd = &cobra.Command{
+	Use:   "revert",
+	Short: "Restore the original OCR of corrected page xml files",
+	Run:   runRevert,
+}
+
+func init() {
+	ApplyCmd.Flags().StringSliceVarP(&applyFlags.ifgs, "input-file-grp", "I", nil,
+		"set input file groups")
+	ApplyCmd.Flags().StringVarP(&applyFlags.ofg, "output-file-grp", "O", "",
+		"set output file group")
+	ApplyCmd.Flags().StringVarP(&applyFlags.mets, "mets", "m", "mets.xml",
+		"set path to the mets file")
+	ApplyCmd.Flags().StringVar(&applyFlags.pages, "page-id", "",
+		"only process the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
+	ApplyCmd.Flags().StringVarP(&applyFlags.stoks, "stoks", "s", "",
+		"read correction decisions from the given stoks file")
+	ApplyCmd.Flags().StringVarP(&applyFlags.keep, "keep", "k", keepNone,
+		"set policy for original TextEquivs of corrected words (none, ocr or all)")
+	ApplyCmd.Flags().Float64VarP(&applyFlags.threshold, "threshold", "t", 0.5,
+		"set the confidence threshold for corrections")
+	ApplyCmd.Flags().BoolVarP(&applyFlags.drop, "drop-glyphs", "G", false,
+		"remove glyphs of corrected words (do not update them)")
+	RevertCmd.Flags().StringSliceVarP(&revertFlags.ifgs, "input-file-grp", "I", nil,
+		"set input file groups")
+	RevertCmd.Flags().StringVarP(&revertFlags.ofg, "output-file-grp", "O", "",
+		"set output file group")
+	RevertCmd.Flags().StringVarP(&revertFlags.mets, "mets", "m", "mets.xml",
+		"set path to the mets file")
+	RevertCmd.Flags().StringVar(&revertFlags.pages, "page-id", "",
+		"only process the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
+	RevertCmd.Flags().StringVarP(&revertFlags.keep, "keep", "k", keepNone,
+		"set policy for original TextEquivs of reverted words (none, ocr or all)")
+	RevertCmd.Flags().BoolVarP(&revertFlags.drop, "drop-glyphs", "G", false,
+		"remove glyphs of reverted words (do not update them)")
+}
+
+func runApply(_ *cobra.Command, args []string) {
+	if applyFlags.stoks == "" {
+		stoks, err := readStoksFromPAGE(applyFlags.mets, applyFlags.pages, applyFlags.ifgs...)
+		chk(err)
+		stoks.apply(applyFlags.threshold)
+		chk(writePAGE(stoks, true, applyFlags.mets, applyFlags.ofg, applyFlags.pages,
+			applyFlags.keep, applyFlags.drop, applyFlags.ifgs...))
+		return
+	}
+	names, stoks, err := readStoksFromFile(applyFlags.stoks)
+	chk(err)
+	for _, s := range stoks {
+		s.apply(applyFlags.threshold)
+	}
+	if applyFlags.ofg == "" {
+		for i, s := range stoks {
+			if i == 0 || names[i] != names[i-1] {
+				fmt.Printf("%s%s\n", internal.StokNamePref, names[i])
+			}
+			fmt.Printf("%s\n", s.Stok)
+		}
+		return
+	}
+	m, err := mets.Open(applyFlags.mets)
+	chk(err)
+	byFile, err := stoksForFiles(m, names, stoks, applyFlags.ifgs...)
+	chk(err)
+	chk(writePAGE(byFile, false, applyFlags.mets, applyFlags.ofg, applyFlags.pages,
+		applyFlags.keep, applyFlags.drop, applyFlags.ifgs...))
+}
+
+func runRevert(_ *cobra.Command, args []string) {
+	stoks, err := readStoksFromPAGE(revertFlags.mets, revertFlags.pages, revertFlags.ifgs...)
+	chk(err)
+	for _, ids := range stoks {
+		for _, s := range ids {
+			s.Cor = false
+		}
+	}
+	chk(writePAGE(stoks, true, revertFlags.mets, revertFlags.ofg, revertFlags.pages,
+		revertFlags.keep, revertFlags.drop, revertFlags.ifgs...))
+}
+
+func writePAGE(stoks stokMap, revert bool, mets, ofg, pages, keep string, drop bool, ifgs ...string) error {
+	if ofg == "" {
+		return fmt.Errorf("missing output file group")
+	}
+	switch keep {
+	case keepNone, keepOCR, keepAll:
+	default:
+		return fmt.Errorf("invalid keep policy: %s", keep)
+	}
+	cor, err := newMETSCorrector(mets, ofg, pages, stoks, ifgs...)
+	if err != nil {
+		return err
+	}
+	cor.revert, cor.keep, cor.drop = revert, keep, drop
+	return cor.correct()
+}
+
+// apply sets the correction decision of the (not skipped) stok using
+// the given threshold.
+func (s *stok) apply(threshold float64) {
+	if s.Skipped {
+		return
+	}
+	s.Cor = s.Conf > threshold
+}
+
+func (m stokMap) apply(threshold float64) {
+	for _, ids := range m {
+		for _, s := range ids {
+			s.apply(threshold)
+		}
+	}
+}
+
+// readStoksFromFile reads the stoks from the given file.  It returns
+// the stoks and their according names in order.
+func readStoksFromFile(name string) ([]string, []*stok, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read stoks %s: %v", name, err)
+	}
+	defer in.Close()
+	var names []string
+	var stoks []*stok
+	err = internal.EachStok(in, func(name string, s internal.Stok) error {
+		names = append(names, name)
+		stoks = append(stoks, &stok{Stok: s, order: len(stoks)})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("read stoks %s: %v", name, err)
+	}
+	return names, stoks, nil
+}
+
+// stoksForFiles assigns the stoks to the files of the given input
+// file groups using the names of their documents and their ids (see
+// pagexml.TokenID).  The stoks of a file group must belong to the
+// document with the same name.
+func stoksForFiles(m mets.METS, names []string, stoks []*stok, ifgs ...string) (stokMap, error) {
+	docs := make(map[string][]*stok)
+	for i, s := range stoks {
+		docs[names[i]] = append(docs[names[i]], s)
+	}
+	ret := make(stokMap)
+	for _, ifg := range ifgs {
+		files, err := m.FilePathsForFileGrp(ifg)
+		if err != nil {
+			return nil, err
+		}
+		bases := make(map[string]string, len(files)) // base -> file
+		for _, file := range files {
+			base := pagexml.TokenID(file, "")
+			bases[base[:len(base)-1]] = file
+		}
+		for _, s := range docs[ifg] {
+			file, ok := fileForID(bases, s.ID)
+			if !ok {
+				continue
+			}
+			if _, ok := ret[file]; !ok {
+				ret[file] = make(map[string]*stok)
+			}
+			ret[file][s.ID] = s
+		}
+	}
+	return ret, nil
+}
+
+// fileForID returns the file of the longest base name that is a
+// prefix of the given token id (see pagexml.TokenID).
+func fileForID(bases map[string]string, id string) (string, bool) {
+	for i := strings.LastIndex(id, "_"); i > 0; i = strings.LastIndex(id[:i], "_") {
+		if file, ok := bases[id[:i]]; ok {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// readStoksFromPAGE reads the stoks from the dataTypeDetails of the
+// words' primary TextEquivs in the page xml files of the given input
+// file groups.  The raw OCR of the stoks is set to the original OCR
+// of the words.
+func readStoksFromPAGE(name, pages string, ifgs ...string) (stokMap, error) {
+	m, err := mets.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("read stoks from page: %v", err)
+	}
+	ids, err := m.PageIDs(pages)
+	if err != nil {
+		return nil, fmt.Errorf("read stoks from page: %v", err)
+	}
+	ret := make(stokMap)
+	for _, ifg := range ifgs {
+		files, err := m.FilePathsForFileGrp(ifg, ids...)
+		if err != nil {
+			return nil, fmt.Errorf("read stoks from page: %v", err)
+		}
+		for _, file := range files {
+			ids, err := readStoksFromPAGEFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("read stoks from page: %v", err)
+			}
+			ret[file] = ids
+		}
+	}
+	return ret, nil
+}
+
+func readStoksFromPAGEFile(file string) (map[string]*stok, error) {
+	is, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer is.Close()
+	doc, err := xmlquery.Parse(is)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	ret := make(map[string]*stok)
+	for _, word := range xmlquery.Find(doc, "//*[local-name()='Word']") {
+		unicodes := pagexml.FindUnicodesInRegionSorted(word)
+		if len(unicodes) == 0 {
+			continue
+		}
+		details, ok := node.LookupAttr(unicodes[0].Parent, xml.Name{Local: "dataTypeDetails"})
+		if !ok {
+			continue
+		}
+		s, err := internal.MakeStokFromLine(details)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		id, _ := node.LookupAttr(word, xml.Name{Local: "id"})
+		s.ID = pagexml.TokenID(file, id)
+		ret[s.ID] = &stok{
+			Stok:  s,
+			raw:   originalOCR(unicodes, s),
+			order: len(ret),
+		}
+	}
+	apoco.Log("read %d stoks from %s", len(ret), filepath.Base(file))
+	return ret, nil
+}
+
+// originalOCR returns the original OCR of a corrected word.  If the
+// master OCR was kept (see --keep), it is used.  Otherwise the OCR
+// is restored from the stok.  Since stoks are normalized, the casing
+// of the corrected word is used.
+func originalOCR(unicodes []*xmlquery.Node, s internal.Stok) string {
+	for _, u := range unicodes[1:] {
+		if dt, _ := node.LookupAttr(u.Parent, xml.Name{Local: "dataType"}); dt == "OCR" {
+			return node.Data(u.FirstChild)
+		}
+	}
+	text := node.Data(unicodes[0].FirstChild)
+	if s.Skipped || !s.Cor {
+		return text
+	}
+	ocr := s.OCR
+	if ocr == internal.Epsilon {
+		ocr = ""
+	}
+	return apoco.ApplyOCRToCorrection(text, strings.ReplaceAll(ocr, "_", " "))
+}
@@ -0,0 +1,175 @@
+package correct
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
+	"github.com/antchfx/xmlquery"
+)
+
+func TestFileForID(t *testing.T) {
+	bases := map[string]string{
+		"p1":   "OCR/p1.xml",
+		"p10":  "OCR/p10.xml",
+		"p1_a": "OCR/p1_a.xml",
+	}
+	for _, tc := range []struct {
+		id, want string
+		ok       bool
+	}{
+		{"p1_w1", "OCR/p1.xml", true},
+		{"p10_w1", "OCR/p10.xml", true},
+		{"p1_a_w1", "OCR/p1_a.xml", true},
+		{"p1_w1_x", "OCR/p1.xml", true},
+		{"p1_l1:2", "OCR/p1.xml", true},
+		{"p10_l1:2", "OCR/p10.xml", true},
+		{"p100_w1", "", false},
+		{"p2_w1", "", false},
+		{"p1", "", false},
+		{"", "", false},
+	} {
+		t.Run(tc.id, func(t *testing.T) {
+			got, ok := fileForID(bases, tc.id)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("expected %q (%t); got %q (%t)", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestStoksForFiles(t *testing.T) {
+	root, err := xmlquery.Parse(strings.NewReader(`<mets:mets xmlns:mets="http://www.loc.gov/METS/" xmlns:xlink="http://www.w3.org/1999/xlink">
+<mets:fileSec>
+<mets:fileGrp USE="OCR">
+<mets:file ID="OCR_p1"><mets:FLocat xlink:href="OCR/p1.xml"/></mets:file>
+<mets:file ID="OCR_p10"><mets:FLocat xlink:href="OCR/p10.xml"/></mets:file>
+</mets:fileGrp>
+<mets:fileGrp USE="GT">
+<mets:file ID="GT_p1"><mets:FLocat xlink:href="GT/p1.xml"/></mets:file>
+</mets:fileGrp>
+</mets:fileSec>
+</mets:mets>`))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	m := mets.METS{Root: root, Name: "ws/mets.xml"}
+	mkstok := func(id string) *stok {
+		return &stok{Stok: internal.Stok{ID: id}}
+	}
+	stoks := []*stok{
+		mkstok("p1_w1"),
+		mkstok("p10_w1"),
+		mkstok("p1_l2:1"),
+		mkstok("p2_w1"),  // no matching file
+		mkstok("p1_w2"),  // document of another file group
+		mkstok("p10_w2"), // document that is not corrected
+	}
+	names := []string{"OCR", "OCR", "OCR", "OCR", "GT", "other"}
+	for _, tc := range []struct {
+		ifgs []string
+		want map[string][]string
+	}{
+		{[]string{"OCR"}, map[string][]string{
+			"ws/OCR/p1.xml":  {"p1_l2:1", "p1_w1"},
+			"ws/OCR/p10.xml": {"p10_w1"},
+		}},
+		{[]string{"GT"}, map[string][]string{
+			"ws/GT/p1.xml": {"p1_w2"},
+		}},
+		{[]string{"OCR", "GT"}, map[string][]string{
+			"ws/OCR/p1.xml":  {"p1_l2:1", "p1_w1"},
+			"ws/OCR/p10.xml": {"p10_w1"},
+			"ws/GT/p1.xml":   {"p1_w2"},
+		}},
+		{[]string{"other"}, map[string][]string{}},
+	} {
+		t.Run(strings.Join(tc.ifgs, ","), func(t *testing.T) {
+			sm, err := stoksForFiles(m, names, stoks, tc.ifgs...)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			got := make(map[string][]string)
+			for file, ids := range sm {
+				for id, s := range ids {
+					if id != s.ID {
+						t.Errorf("invalid stok %s for id %s", s.ID, id)
+					}
+					got[file] = append(got[file], id)
+				}
+				sort.Strings(got[file])
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOriginalOCR(t *testing.T) {
+	for _, tc := range []struct {
+		name, word, want string
+		stok             internal.Stok
+	}{
+		{
+			"kept ocr",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Und</Unicode></TextEquiv>` +
+				`<TextEquiv index="2" dataType="OCR"><Unicode>Vnd,</Unicode></TextEquiv>`,
+			"Vnd,",
+			internal.Stok{OCR: "vnb", Sug: "und", Cor: true},
+		},
+		{
+			"kept ocr (not first in document order)",
+			`<TextEquiv index="2" dataType="OCR"><Unicode>Vnd</Unicode></TextEquiv>` +
+				`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Und</Unicode></TextEquiv>`,
+			"Vnd",
+			internal.Stok{OCR: "vnd", Sug: "und", Cor: true},
+		},
+		{
+			"reconstructed ocr",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Und</Unicode></TextEquiv>`,
+			"Vnd",
+			internal.Stok{OCR: "vnd", Sug: "und", Cor: true},
+		},
+		{
+			"reconstructed ocr (kept all)",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Und</Unicode></TextEquiv>` +
+				`<TextEquiv index="2"><Unicode>Vnd</Unicode></TextEquiv>`,
+			"Vnd",
+			internal.Stok{OCR: "vnd", Sug: "und", Cor: true},
+		},
+		{
+			"reconstructed merged ocr",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Und</Unicode></TextEquiv>`,
+			"Vn d",
+			internal.Stok{OCR: "vn_d", Sug: "und", Cor: true},
+		},
+		{
+			"not corrected",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Vnd</Unicode></TextEquiv>`,
+			"Vnd",
+			internal.Stok{OCR: "vnd", Sug: "und"},
+		},
+		{
+			"skipped",
+			`<TextEquiv index="1" dataType="OCR-D-CIS-POST-CORRECTION"><Unicode>Vnd</Unicode></TextEquiv>`,
+			"Vnd",
+			internal.Stok{OCR: "vnd", Skipped: true, Cor: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader(`<Word id="w1">` + tc.word + `</Word>`))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			unicodes := pagexml.FindUnicodesInRegionSorted(xmlquery.FindOne(doc, "/Word"))
+			if got := originalOCR(unicodes, tc.stok); got != tc.want {
+				t.Errorf("expected %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -8,14 +8,16 @@ import (
 
 	"git.sr.ht/~flobar/apoco/cmd/internal"
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
 	"github.com/spf13/cobra"
 )
 
 var flags = struct {
-	ifgs, exts                             []string
-	ofg, mets, model, params, profile, suf string
-	nocr, cands                            int
-	cache, gt, correct                     bool
+	ifgs, exts                                          []string
+	ofg, mets, model, params, profile, suf, keep, pages string
+	nocr, cands, alts                                   int
+	cache, gt, correct, lines, words, drop              bool
+	notes                                               []mets.Note // Agent notes (OCR-D processor interface).
 }{}
 
 // Cmd runs the apoco correct command.
@@ -32,6 +34,8 @@ func init() {
 		[]string{".xml"}, "set input file extensions")
 	Cmd.Flags().StringVarP(&flags.ofg, "output-file-grp", "O",
 		"", "set output file group")
+	Cmd.Flags().StringVar(&flags.pages, "page-id", "",
+		"only process the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
 	Cmd.Flags().StringVarP(&flags.mets, "mets", "m",
 		"mets.xml", "set path to the mets file")
 	Cmd.Flags().StringVarP(&flags.params, "parameter", "p",
@@ -44,11 +48,18 @@ func init() {
 		0, "set nocr (overwrites setting in the configuration file)")
 	Cmd.Flags().IntVarP(&flags.cands, "cands", "d",
 		-1, "output candidates for tokens (0=all, -1=no)")
+	Cmd.Flags().IntVarP(&flags.alts, "alternatives", "a",
+		0, "write the top-k candidates as alternative TextEquivs into corrected words (see --correct)")
 	Cmd.Flags().StringVarP(&flags.model, "model", "M", "",
 		"set model path (overwrites setting in the configuration file)")
 	Cmd.Flags().BoolVarP(&flags.cache, "cache", "c", false, "enable caching of profile")
 	Cmd.Flags().BoolVarP(&flags.gt, "gt", "g", false, "enable ground-truth data")
 	Cmd.Flags().BoolVarP(&flags.correct, "correct", "C", false, "do not output stoks; correct files directly")
+	Cmd.Flags().BoolVarP(&flags.lines, "lines", "L", false, "tokenize page xml files on the line level (ignore words)")
+	Cmd.Flags().BoolVarP(&flags.words, "words", "W", false, "insert generated words into corrected lines (see --lines)")
+	Cmd.Flags().StringVarP(&flags.keep, "keep", "k", keepNone,
+		"set policy for original TextEquivs of corrected words (none, ocr or all)")
+	Cmd.Flags().BoolVarP(&flags.drop, "drop-glyphs", "G", false, "remove glyphs of corrected words (do not update them)")
 }
 
 func run(_ *cobra.Command, args []string) {
@@ -60,40 +71,75 @@ func run(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.GT, flags.gt)
 	m, err := internal.ReadModel(c.Model, c.LM, false)
 	chk(err)
-	rrlr, rrfs, err := m.Get("rr", c.Nocr)
+	p := internal.Piper{
+		IFGS:     flags.ifgs,
+		METS:     flags.mets,
+		Exts:     flags.exts,
+		Dirs:     args,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		Lines:    flags.lines,
+		Pages:    flags.pages,
+	}
+	stoks, err := pipe(context.Background(), p, c, m, flags.profile, flags.gt)
 	chk(err)
-	dmlr, dmfs, err := m.Get("dm", c.Nocr)
+	apoco.Log("correcting %d pages (%d tokens)", len(stoks), stoks.numberOfTokens())
+	// Add additional arguments to the input file groups.
+	flags.ifgs = append(args, flags.ifgs...)
+	cor, err := mkcorrector(stoks)
 	chk(err)
-	stoks := make(stokMap)
-	p := internal.Piper{
-		IFGS: flags.ifgs,
-		METS: flags.mets,
-		Exts: flags.exts,
-		Dirs: args,
+	chk(cor.correct())
+}
+
+// Stoks runs the correction pipeline on the tokens of the given piper
+// and returns the resulting stoks in the order of their tokens.  If
+// gt is set, the last token of each token is used as ground-truth.
+func Stoks(ctx context.Context, p internal.Piper, c *internal.Config, m *internal.Model, gt bool) ([]internal.Stok, error) {
+	stoks, err := pipe(ctx, p, c, m, "", gt)
+	if err != nil {
+		return nil, err
+	}
+	sorted := stoks.sorted()
+	ret := make([]internal.Stok, len(sorted))
+	for i := range sorted {
+		ret[i] = sorted[i].Stok
+	}
+	return ret, nil
+}
+
+func pipe(ctx context.Context, p internal.Piper, c *internal.Config, m *internal.Model, profile string, gt bool) (stokMap, error) {
+	rrlr, rrfs, err := m.Get("rr", c.Nocr)
+	if err != nil {
+		return nil, err
+	}
+	dmlr, dmfs, err := m.Get("dm", c.Nocr)
+	if err != nil {
+		return nil, err
 	}
-	chk(p.Pipe(
-		context.Background(),
+	stoks := make(stokMap)
+	err = p.Pipe(
+		ctx,
 		apoco.FilterBad(c.Nocr),
 		register(stoks),
+		internal.JoinHyphenations(c),
 		apoco.Normalize(),
-		addTokens(stoks, flags.gt),
+		addTokens(stoks, gt),
 		filterShort(stoks),
 		apoco.ConnectLanguageModel(m.LM),
 		apoco.ConnectUnigrams(),
-		connectProfile(c, m.LM, flags.profile),
+		connectProfile(c, m.LM, profile),
 		filterLex(stoks),
 		apoco.ConnectCandidates(),
+		internal.ConnectOCRCandidates(c),
 		apoco.ConnectRankings(rrlr, rrfs, c.Nocr),
-		analyzeRankings(stoks, flags.gt),
+		analyzeRankings(stoks, gt),
 		apoco.ConnectCorrections(dmlr, dmfs, c.Nocr),
 		correct(stoks),
-	))
-	apoco.Log("correcting %d pages (%d tokens)", len(stoks), stoks.numberOfTokens())
-	// Add additional arguments to the input file groups.
-	flags.ifgs = append(args, flags.ifgs...)
-	cor, err := mkcorrector(stoks)
-	chk(err)
-	chk(cor.correct())
+	)
+	if err != nil {
+		return nil, err
+	}
+	return stoks, nil
 }
 
 func mkcorrector(stoks stokMap) (corrector, error) {
@@ -101,7 +147,18 @@ func mkcorrector(stoks stokMap) (corrector, error) {
 		return snippetCorrector{stoks, flags.exts[0], flags.suf}, nil
 	}
 	if flags.correct {
-		return newMETSCorrector(flags.mets, flags.ofg, stoks, flags.ifgs...)
+		switch flags.keep {
+		case keepNone, keepOCR, keepAll:
+		default:
+			return nil, fmt.Errorf("invalid keep policy: %s", flags.keep)
+		}
+		cor, err := newMETSCorrector(flags.mets, flags.ofg, flags.pages, stoks, flags.ifgs...)
+		if err != nil {
+			return nil, err
+		}
+		cor.lines, cor.words, cor.drop = flags.lines, flags.words, flags.drop
+		cor.alts, cor.keep, cor.notes = flags.alts, flags.keep, flags.notes
+		return cor, nil
 	}
 	return stokCorrector{stoks}, nil
 }
@@ -109,11 +166,16 @@ func mkcorrector(stoks stokMap) (corrector, error) {
 func correct(m stokMap) apoco.StreamFunc {
 	return func(ctx context.Context, in <-chan apoco.T, _ chan<- apoco.T) error {
 		return apoco.EachToken(ctx, in, func(t apoco.T) error {
-			stok := m.get(t)
-			stok.Skipped = false
-			stok.Cor = t.Payload.(apoco.Correction).Conf > 0.5
-			stok.Conf = t.Payload.(apoco.Correction).Conf
-			stok.Sug = t.Payload.(apoco.Correction).Candidate.Suggestion
+			cor := t.Payload.(apoco.Correction)
+			// Distribute the correction over the parts of
+			// joined hyphenated tokens.
+			sugs := t.SplitHyphenation(cor.Candidate.Suggestion)
+			for i, stok := range m.parts(t) {
+				stok.Skipped = false
+				stok.Cor = cor.Conf > 0.5
+				stok.Conf = cor.Conf
+				stok.Sug = sugs[i]
+			}
 			return nil
 		})
 	}
@@ -142,11 +204,18 @@ func addTokens(m stokMap, withGT bool) apoco.StreamFunc {
 		return apoco.EachToken(ctx, in, func(t apoco.T) error {
 			// Each token gets its ID. It is skipped by default. If a token
 			// should not be skipped, skipped must be explicitly set to false.
-			stok := m.get(t)
-			stok.Stok = internal.MakeStokFromT(t, withGT)
-			stok.ID = t.ID
-			stok.document = t.Document
-			stok.Skipped = true
+			// Joined hyphenated tokens add each of their parts.
+			parts := t.Parts
+			if len(parts) == 0 {
+				parts = []apoco.T{t}
+			}
+			for _, p := range parts {
+				stok := m.get(p)
+				stok.Stok = internal.MakeStokFromT(p, withGT)
+				stok.ID = p.ID
+				stok.document = p.Document
+				stok.Skipped = true
+			}
 			if err := apoco.SendTokens(ctx, out, t); err != nil {
 				return fmt.Errorf("add tokens: %v", err)
 			}
@@ -158,11 +227,13 @@ func addTokens(m stokMap, withGT bool) apoco.StreamFunc {
 func filterLex(m stokMap) apoco.StreamFunc {
 	return func(ctx context.Context, in <-chan apoco.T, out chan<- apoco.T) error {
 		return apoco.EachToken(ctx, in, func(t apoco.T) error {
-			if t.IsLexiconEntry() {
-				m.get(t).Lex = true
+			lex := t.IsLexiconEntry()
+			for _, stok := range m.parts(t) {
+				stok.Lex = lex || t.ContainsLexiconEntry()
+			}
+			if lex {
 				return nil
 			}
-			m.get(t).Lex = t.ContainsLexiconEntry()
 			if err := apoco.SendTokens(ctx, out, t); err != nil {
 				return fmt.Errorf("filterLex: %v", err)
 			}
@@ -175,7 +246,9 @@ func filterShort(m stokMap) apoco.StreamFunc {
 	return func(ctx context.Context, in <-chan apoco.T, out chan<- apoco.T) error {
 		return apoco.EachToken(ctx, in, func(t apoco.T) error {
 			if utf8.RuneCountInString(t.Tokens[0]) <= 3 {
-				m.get(t).Short = true
+				for _, stok := range m.parts(t) {
+					stok.Short = true
+				}
 				return nil
 			}
 			if err := apoco.SendTokens(ctx, out, t); err != nil {
@@ -189,17 +262,25 @@ func filterShort(m stokMap) apoco.StreamFunc {
 func analyzeRankings(m stokMap, withGT bool) apoco.StreamFunc {
 	return func(ctx context.Context, in <-chan apoco.T, out chan<- apoco.T) error {
 		return apoco.EachToken(ctx, in, func(t apoco.T) error {
-			info := m.get(t)
-			info.rankings = t.Payload.([]apoco.Ranking)
+			rankings := t.Payload.([]apoco.Ranking)
+			var rank int
 			if withGT {
-				var rank int
-				for i, r := range info.rankings {
+				for i, r := range rankings {
 					if r.Candidate.Suggestion == t.Tokens[len(t.Tokens)-1] {
 						rank = i + 1
 						break
 					}
 				}
-				m.get(t).Rank = rank
+			}
+			// Distribute the rankings over the parts of joined
+			// hyphenated tokens.
+			parts := m.parts(t)
+			for i, info := range parts {
+				info.Rank = rank
+				info.rankings = rankings
+				if len(parts) > 1 {
+					info.rankings = splitRankings(t, rankings, i)
+				}
 			}
 			if err := apoco.SendTokens(ctx, out, t); err != nil {
 				return fmt.Errorf("analyzeRankings: %v", err)
@@ -209,6 +290,18 @@ func analyzeRankings(m stokMap, withGT bool) apoco.StreamFunc {
 	}
 }
 
+// splitRankings returns the rankings of the i-th part of the joined
+// hyphenated token t.
+func splitRankings(t apoco.T, rankings []apoco.Ranking, i int) []apoco.Ranking {
+	ret := make([]apoco.Ranking, len(rankings))
+	for j, r := range rankings {
+		cand := *r.Candidate
+		cand.Suggestion = t.SplitHyphenation(r.Candidate.Suggestion)[i]
+		ret[j] = apoco.Ranking{Candidate: &cand, Prob: r.Prob}
+	}
+	return ret
+}
+
 func connectProfile(c *internal.Config, lm map[string]*apoco.FreqList, profile string) apoco.StreamFunc {
 	if profile == "" {
 		return internal.ConnectProfile(c, "-profiler.json.gz")
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"git.sr.ht/~flobar/apoco/cmd/internal"
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
@@ -28,17 +29,25 @@ type metsCorrector struct {
 	stoks   stokMap
 	ofg     string
 	ifgs    []string
+	pages   []string // Selected page ids (all pages if empty).
 	fileGrp *xmlquery.Node
 	mets    mets.METS
+	lines   bool        // Correct the text of lines instead of words.
+	words   bool        // Insert generated words into corrected lines.
+	drop    bool        // Drop glyphs of corrected words.
+	alts    int         // Number of alternative candidates to write.
+	keep    string      // Policy for original TextEquivs (keepNone, keepOCR or keepAll).
+	revert  bool        // Use the raw OCR of the stoks as the words' OCR.
+	notes   []mets.Note // Notes of the agent (OCR-D parameters).
 }
 
-func newMETSCorrector(mets, ofg string, stoks stokMap, ifgs ...string) (*metsCorrector, error) {
+func newMETSCorrector(mets, ofg, pages string, stoks stokMap, ifgs ...string) (*metsCorrector, error) {
 	cor := metsCorrector{
 		stoks: stoks,
 		ofg:   ofg,
 		ifgs:  ifgs,
 	}
-	if err := cor.readMETS(mets); err != nil {
+	if err := cor.readMETS(mets, pages); err != nil {
 		return nil, err
 	}
 	return &cor, nil
@@ -46,7 +55,7 @@ func newMETSCorrector(mets, ofg string, stoks stokMap, ifgs ...string) (*metsCor
 
 func (cor *metsCorrector) correct() error {
 	for _, ifg := range cor.ifgs {
-		files, err := cor.mets.FilePathsForFileGrp(ifg)
+		files, err := cor.mets.FilePathsForFileGrp(ifg, cor.pages...)
 		if err != nil {
 			return fmt.Errorf("correct: %v", err)
 		}
@@ -56,6 +65,10 @@ func (cor *metsCorrector) correct() error {
 			}
 		}
 	}
+	// Update agent in mets header file.
+	if err := cor.addAgent(); err != nil {
+		return fmt.Errorf("correct: %v", err)
+	}
 	if err := cor.mets.Write(); err != nil {
 		return fmt.Errorf("correct: %v", err)
 	}
@@ -73,23 +86,31 @@ func (cor *metsCorrector) correctFile(file, ifg string) error {
 	if err != nil {
 		return fmt.Errorf("writeCorrections: %v", err)
 	}
-	// Set correction to Word nodes.
-	words := xmlquery.Find(doc, "//*[local-name()='Word']")
-	for _, word := range words {
-		if err := cor.correctWord(word, file); err != nil {
-			return fmt.Errorf("correct %s: %v", file, err)
+	if cor.lines {
+		// Set corrections directly to the TextLine nodes.
+		lines := xmlquery.Find(doc, "//*[local-name()='TextLine']")
+		for _, line := range lines {
+			cor.correctLine(line, file)
+		}
+	} else {
+		// Set correction to Word nodes.
+		words := xmlquery.Find(doc, "//*[local-name()='Word']")
+		for _, word := range words {
+			if err := cor.correctWord(word, file); err != nil {
+				return fmt.Errorf("correct %s: %v", file, err)
+			}
+		}
+		// Use corrected words to write new lines.
+		lines := xmlquery.Find(doc, "//*[local-name()='TextLine']")
+		for _, line := range lines {
+			words := gatherUnicodes(line, "./*[local-name()='Word']")
+			resetTextEquiv(line, strings.Join(words, " "))
 		}
-	}
-	// Use corrected words to write new lines.
-	lines := xmlquery.Find(doc, "//*[local-name()='TextLine']")
-	for _, line := range lines {
-		words := gatherUnicodes(line, "./*[local-name()='Word']/*[local-name()='TextEquiv']/*[local-name()='Unicode']")
-		resetTextEquiv(line, strings.Join(words, " "))
 	}
 	// Use corrected lines to write new regions.
 	regions := xmlquery.Find(doc, "//*[local-name()='TextRegion']")
 	for _, region := range regions {
-		lines := gatherUnicodes(region, "./*[local-name()='TextLine']/*[local-name()='TextEquiv']/*[local-name()='Unicode']")
+		lines := gatherUnicodes(region, "./*[local-name()='TextLine']")
 		resetTextEquiv(region, strings.Join(lines, "\n"))
 	}
 	if err := cor.write(doc, file, ifg); err != nil {
@@ -98,6 +119,15 @@ func (cor *metsCorrector) correctFile(file, ifg string) error {
 	return nil
 }
 
+// prefixID prefixes the id of the stok with the output file group and
+// the file name.  Ids that already carry the prefix are not changed.
+func (cor *metsCorrector) prefixID(info *stok, file string) {
+	prefix := internal.IDFromFilePath(file, cor.ofg) + "_"
+	if !strings.HasPrefix(info.ID, prefix) {
+		info.ID = prefix + info.ID
+	}
+}
+
 func (cor *metsCorrector) correctWord(word *xmlquery.Node, file string) error {
 	id, _ := node.LookupAttr(word, xml.Name{Local: "id"})
 	unicodes := pagexml.FindUnicodesInRegionSorted(word)
@@ -108,13 +138,16 @@ func (cor *metsCorrector) correctWord(word *xmlquery.Node, file string) error {
 	newU := newUnicode(unicodes[0].Parent, "")
 	ocr := node.Data(unicodes[0].FirstChild)
 
-	info := cor.stoks[file][id]
+	info := cor.stoks[file][pagexml.TokenID(file, id)]
 	// Just skip words that we do not have any info about.
 	if info == nil {
 		return nil
 	}
-	prefix := internal.IDFromFilePath(file, cor.ofg)
-	info.ID = prefix + "_" + info.ID // Prefix the id with the output file group and the file name.
+	if cor.revert {
+		ocr = info.raw
+		unicodes = cor.revertWord(unicodes, ocr)
+	}
+	cor.prefixID(info, file)
 	if info.Skipped {
 		newU.FirstChild.Data = ocr
 		node.SetAttr(newTE, xml.Attr{
@@ -139,14 +172,213 @@ func (cor *metsCorrector) correctWord(word *xmlquery.Node, file string) error {
 	newTE.FirstChild = newU
 	newU.Parent = newTE
 	node.PrependSibling(unicodes[0].Parent, newTE)
-	cor.cleanWord(word, unicodes)
+	prev, index := newTE, 2
+	if cor.keep == keepOCR {
+		// The kept master OCR directly follows the correction
+		// (index 2) and the alternatives follow the master OCR.
+		prev, index = unicodes[0].Parent, 3
+	}
+	index = cor.appendAlternatives(prev, index, ocr, newU.FirstChild.Data, info)
+	cor.cleanWord(word, unicodes, index)
+	if !cor.drop {
+		pagexml.UpdateGlyphs(word, newU.FirstChild.Data, cor.cleanGlyph, glyphAttrs(newTE)...)
+	}
 	return nil
 }
 
-func (cor *metsCorrector) cleanWord(word *xmlquery.Node, unicodes []*xmlquery.Node) {
-	// Remove other TextEquivs.
+// glyphAttrs returns the conf and dataType attributes of the given
+// TextEquiv node for updated glyphs.
+func glyphAttrs(te *xmlquery.Node) []xml.Attr {
+	var ret []xml.Attr
+	for _, key := range []string{"conf", "dataType"} {
+		if val, ok := node.LookupAttr(te, xml.Name{Local: key}); ok && val != "" {
+			ret = append(ret, xml.Attr{Name: xml.Name{Local: key}, Value: val})
+		}
+	}
+	return ret
+}
+
+// correctLine replaces the text of the line with its corrected
+// words.  Any existing words of the line are removed.  If words is
+// set, new words are generated for the tokens of the line.
+func (cor *metsCorrector) correctLine(line *xmlquery.Node, file string) {
+	unicodes := pagexml.FindUnicodesInRegionSorted(line)
+	if len(unicodes) == 0 {
+		return
+	}
+	id, _ := node.LookupAttr(line, xml.Name{Local: "id"})
+	var strs []string
+	var words []*xmlquery.Node
+	for i, ocr := range pagexml.LineWords(node.Data(node.FirstChild(unicodes[0]))) {
+		if ocr == "" {
+			continue
+		}
+		text := ocr
+		info := cor.stoks[file][pagexml.LineTokenID(file, id, i+1)]
+		if info != nil && !info.Skipped && info.Cor {
+			text = apoco.ApplyOCRToCorrection(ocr, info.Sug)
+		}
+		strs = append(strs, text)
+		if cor.words {
+			wid := id + "_w" + strconv.Itoa(len(words)+1)
+			words = append(words, cor.newWord(line, wid, text, file, info))
+		}
+	}
+	for _, word := range xmlquery.Find(line, "./*[local-name()='Word']") {
+		node.Delete(word)
+	}
+	if box, ok := pagexml.BoundingBox(line); ok && len(words) > 0 {
+		// Estimate the coordinates of the words by splitting the
+		// line's bounding box at the character offsets of the words.
+		var weights []int
+		for i, str := range strs {
+			if i > 0 {
+				weights = append(weights, 1) // Space between the words.
+			}
+			weights = append(weights, utf8.RuneCountInString(str))
+		}
+		boxes := pagexml.SplitBoundingBox(box, weights...)
+		for i, word := range words {
+			pagexml.SetCoords(word, boxes[2*i])
+		}
+	}
+	resetTextEquiv(line, strings.Join(strs, " "))
+	// Words must be placed before the line's TextEquiv.
+	te := xmlquery.FindOne(line, "./*[local-name()='TextEquiv']")
+	for _, word := range words {
+		node.PrependSibling(te, word)
+	}
+}
+
+// newWord creates a new word node for a token of the given line.
+// Since the position of the word is unknown, its coordinates are
+// estimated later (see correctLine).
+func (cor *metsCorrector) newWord(line *xmlquery.Node, id, text, file string, info *stok) *xmlquery.Node {
+	word := &xmlquery.Node{
+		Type:         xmlquery.ElementNode,
+		Data:         "Word",
+		Prefix:       line.Prefix,
+		NamespaceURI: line.NamespaceURI,
+	}
+	node.SetAttr(word, xml.Attr{Name: xml.Name{Local: "id"}, Value: id})
+	te := &xmlquery.Node{
+		Type:         xmlquery.ElementNode,
+		Data:         "TextEquiv",
+		Prefix:       line.Prefix,
+		NamespaceURI: line.NamespaceURI,
+	}
+	node.SetAttr(te, xml.Attr{Name: xml.Name{Local: "index"}, Value: "1"})
+	if info != nil {
+		cor.prefixID(info, file)
+		if !info.Skipped {
+			node.SetAttr(te, xml.Attr{
+				Name:  xml.Name{Local: "conf"},
+				Value: strconv.FormatFloat(info.Conf, 'e', -1, 64),
+			})
+		}
+		node.SetAttr(te, xml.Attr{
+			Name:  xml.Name{Local: "dataType"},
+			Value: "OCR-D-CIS-POST-CORRECTION",
+		})
+		node.SetAttr(te, xml.Attr{
+			Name:  xml.Name{Local: "dataTypeDetails"},
+			Value: info.String(),
+		})
+	}
+	node.AppendChild(te, newUnicode(te, text))
+	node.AppendChild(word, te)
+	if info != nil {
+		cor.appendAlternatives(te, 2, info.raw, text, info)
+	}
+	return word
+}
+
+// appendAlternatives appends the top-k ranked candidates of the given
+// token as alternative TextEquivs (starting with the given index)
+// after the given TextEquiv te.  Candidates that equal the chosen
+// text are skipped.  It returns the next free index.
+func (cor *metsCorrector) appendAlternatives(te *xmlquery.Node, index int, ocr, text string, info *stok) int {
+	if cor.alts <= 0 || info == nil || info.Skipped {
+		return index
+	}
+	prev, end := te, index+cor.alts
+	for _, r := range info.rankings {
+		if index >= end {
+			break
+		}
+		alt := apoco.ApplyOCRToCorrection(ocr, r.Candidate.Suggestion)
+		if alt == text {
+			continue
+		}
+		newTE := &xmlquery.Node{
+			Type:         xmlquery.ElementNode,
+			Data:         te.Data,
+			Prefix:       te.Prefix,
+			NamespaceURI: te.NamespaceURI,
+		}
+		node.SetAttr(newTE, xml.Attr{
+			Name:  xml.Name{Local: "index"},
+			Value: strconv.Itoa(index),
+		})
+		node.SetAttr(newTE, xml.Attr{
+			Name:  xml.Name{Local: "conf"},
+			Value: strconv.FormatFloat(r.Prob, 'e', -1, 64),
+		})
+		node.SetAttr(newTE, xml.Attr{
+			Name:  xml.Name{Local: "dataType"},
+			Value: "OCR-D-CIS-POST-CORRECTION-CANDIDATE",
+		})
+		node.AppendChild(newTE, newUnicode(newTE, alt))
+		node.AppendSibling(prev, newTE)
+		prev = newTE
+		index++
+	}
+	return index
+}
+
+// revertWord replaces the TextEquivs of previous corrections (and a
+// previously kept master OCR) of a word with a new TextEquiv for the
+// given original OCR.  It returns the updated list of the word's
+// Unicode nodes.
+func (cor *metsCorrector) revertWord(unicodes []*xmlquery.Node, ocr string) []*xmlquery.Node {
+	te := &xmlquery.Node{
+		Type:         xmlquery.ElementNode,
+		Data:         unicodes[0].Parent.Data,
+		Prefix:       unicodes[0].Parent.Prefix,
+		NamespaceURI: unicodes[0].Parent.NamespaceURI,
+	}
+	node.SetAttr(te, xml.Attr{
+		Name:  xml.Name{Local: "index"},
+		Value: "1",
+	})
+	u := newUnicode(te, ocr)
+	node.AppendChild(te, u)
+	node.PrependSibling(unicodes[0].Parent, te)
+	return append([]*xmlquery.Node{u}, removeCorrections(unicodes)...)
+}
+
+// removeCorrections removes the TextEquivs of previous corrections
+// (and of a previously kept master OCR) of the given Unicode nodes.
+// It returns the remaining Unicode nodes.
+func removeCorrections(unicodes []*xmlquery.Node) []*xmlquery.Node {
+	var ret []*xmlquery.Node
 	for _, u := range unicodes {
-		node.Delete(u.Parent)
+		dt, _ := node.LookupAttr(u.Parent, xml.Name{Local: "dataType"})
+		if dt == "OCR" || strings.HasPrefix(dt, "OCR-D-CIS-POST-CORRECTION") {
+			node.Delete(u.Parent)
+			continue
+		}
+		ret = append(ret, u)
+	}
+	return ret
+}
+
+// cleanWord handles the original TextEquivs of a corrected word
+// according to the keep policy (see keepTextEquivs).
+func (cor *metsCorrector) cleanWord(word *xmlquery.Node, unicodes []*xmlquery.Node, index int) {
+	cor.keepTextEquivs(unicodes, index)
+	if !cor.drop {
+		return
 	}
 	// Remove glyph nodes.
 	for _, glyph := range xmlquery.Find(word, "./*[local-name()='Glyph']") {
@@ -154,6 +386,52 @@ func (cor *metsCorrector) cleanWord(word *xmlquery.Node, unicodes []*xmlquery.No
 	}
 }
 
+// cleanGlyph handles the original TextEquivs of an updated glyph
+// according to the keep policy (see keepTextEquivs).  If corrections
+// are reverted, the TextEquivs of previous corrections are removed
+// first.
+func (cor *metsCorrector) cleanGlyph(unicodes []*xmlquery.Node) {
+	if cor.revert {
+		unicodes = removeCorrections(unicodes)
+	}
+	cor.keepTextEquivs(unicodes, 2)
+}
+
+// keepTextEquivs handles the given original TextEquivs according to
+// the keep policy.  If only the master OCR is kept, it gets index 2.
+// If all TextEquivs are kept, they are re-indexed starting with the
+// given index.
+func (cor *metsCorrector) keepTextEquivs(unicodes []*xmlquery.Node, index int) {
+	for i, u := range unicodes {
+		switch {
+		case cor.keep == keepAll:
+			node.SetAttr(u.Parent, xml.Attr{
+				Name:  xml.Name{Local: "index"},
+				Value: strconv.Itoa(index),
+			})
+			index++
+		case cor.keep == keepOCR && i == 0:
+			node.SetAttr(u.Parent, xml.Attr{
+				Name:  xml.Name{Local: "index"},
+				Value: "2",
+			})
+			node.SetAttr(u.Parent, xml.Attr{
+				Name:  xml.Name{Local: "dataType"},
+				Value: "OCR",
+			})
+		default:
+			node.Delete(u.Parent)
+		}
+	}
+}
+
+// Policies for the original TextEquivs of corrected words.
+const (
+	keepNone = "none" // Replace all original TextEquivs.
+	keepOCR  = "ocr"  // Keep the master OCR.
+	keepAll  = "all"  // Keep all original TextEquivs.
+)
+
 func (cor *metsCorrector) makeTextEquiv(p *xmlquery.Node) *xmlquery.Node {
 	newTE := &xmlquery.Node{ // TextEquiv
 		Type:         xmlquery.ElementNode,
@@ -190,7 +468,14 @@ func (cor *metsCorrector) write(doc *xmlquery.Node, file, ifg string) error {
 
 const agent = "ocrd/cis/apoco-correct " + internal.Version
 
-func (cor *metsCorrector) readMETS(name string) error {
+func (cor *metsCorrector) addAgent() error {
+	if len(cor.notes) == 0 {
+		return cor.mets.AddAgent(internal.PStep, agent)
+	}
+	return cor.mets.AppendAgent(internal.PStep, agent, cor.notes...)
+}
+
+func (cor *metsCorrector) readMETS(name, pages string) error {
 	fail := func(err error) error {
 		return fmt.Errorf("read mets %s: %v", name, err)
 	}
@@ -199,16 +484,14 @@ func (cor *metsCorrector) readMETS(name string) error {
 		return fail(err)
 	}
 	cor.mets = m
-	// Update agent in mets header file.
-	if err := cor.mets.AddAgent(internal.PStep, agent); err != nil {
+	if cor.pages, err = m.PageIDs(pages); err != nil {
 		return fail(err)
 	}
 	// Check if the given file group already exists and overwrite it.
 	existing := xmlquery.FindOne(cor.mets.Root, fmt.Sprintf("//*[local-name()='fileGrp'][@USE=%q]", cor.ofg))
 	if existing != nil {
-		// Delete all children.
-		existing.FirstChild = nil
-		existing.LastChild = nil
+		// Delete all children (of the selected pages).
+		cor.mets.ClearFileGrp(existing, cor.pages...)
 		cor.fileGrp = existing
 		return nil
 	}
@@ -327,11 +610,16 @@ func newUnicode(p *xmlquery.Node, data string) *xmlquery.Node {
 	return unicode
 }
 
+// gatherUnicodes returns the primary text of the nodes found with
+// the given expression.
 func gatherUnicodes(p *xmlquery.Node, expr string) []string {
-	unicodes := xmlquery.Find(p, expr)
 	var ret []string
-	for _, u := range unicodes {
-		ret = append(ret, u.FirstChild.Data)
+	for _, n := range xmlquery.Find(p, expr) {
+		unicodes := pagexml.FindUnicodesInRegionSorted(n)
+		if len(unicodes) == 0 {
+			continue
+		}
+		ret = append(ret, node.Data(unicodes[0].FirstChild))
 	}
 	return ret
 }
@@ -363,17 +651,8 @@ type stokCorrector struct {
 }
 
 func (cor stokCorrector) correct() error {
-	sorted := make([]*stok, 0, len(cor.stoks))
-	for _, ids := range cor.stoks {
-		for _, info := range ids {
-			sorted = append(sorted, info)
-		}
-	}
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].order < sorted[j].order
-	})
 	var doc *apoco.Document
-	for _, info := range sorted {
+	for _, info := range cor.stoks.sorted() {
 		if info.document != doc {
 			fmt.Printf("#name=%s\n", info.document.Group)
 			doc = info.document
@@ -0,0 +1,173 @@
+package correct
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
+	"github.com/antchfx/xmlquery"
+	"github.com/finkf/gofiler"
+)
+
+const testWord = `<Word id="w1"><Coords points="0,0 29,0 29,9 0,9"/>
+<Glyph id="g1"><Coords points="0,0 9,0 9,9 0,9"/><TextEquiv index="1"><Unicode>V</Unicode></TextEquiv></Glyph>
+<Glyph id="g2"><Coords points="10,0 19,0 19,9 10,9"/><TextEquiv index="1"><Unicode>n</Unicode></TextEquiv></Glyph>
+<Glyph id="g3"><Coords points="20,0 29,0 29,9 20,9"/><TextEquiv index="1"><Unicode>d</Unicode></TextEquiv></Glyph>
+<TextEquiv index="1" conf="0.8"><Unicode>Vnd</Unicode></TextEquiv>
+<TextEquiv index="2"><Unicode>Und</Unicode></TextEquiv>
+</Word>`
+
+// textEquivs returns the TextEquivs of the given node in document
+// order as index:dataType:conf:text.
+func textEquivs(n *xmlquery.Node) []string {
+	var ret []string
+	for _, te := range xmlquery.Find(n, "./*[local-name()='TextEquiv']") {
+		dt := strings.TrimPrefix(te.SelectAttr("dataType"), "OCR-D-CIS-POST-CORRECTION")
+		ret = append(ret, strings.Join([]string{
+			te.SelectAttr("index"), dt, te.SelectAttr("conf"), strings.TrimSpace(te.InnerText()),
+		}, ":"))
+	}
+	return ret
+}
+
+func TestCorrectWord(t *testing.T) {
+	const file = "OCR/OCR_0001.xml"
+	for _, tc := range []struct {
+		name, keep  string
+		alts        int
+		word, glyph []string
+	}{
+		{"none", keepNone, 0,
+			[]string{"1::9e-01:Und"},
+			[]string{"1::9e-01:U"}},
+		{"none/alts=1", keepNone, 1,
+			[]string{"1::9e-01:Und", "2:-CANDIDATE:5e-02:Vnd"},
+			[]string{"1::9e-01:U"}},
+		{"none/alts=2", keepNone, 2,
+			[]string{"1::9e-01:Und", "2:-CANDIDATE:5e-02:Vnd", "3:-CANDIDATE:3e-02:And"},
+			[]string{"1::9e-01:U"}},
+		{"none/alts=5", keepNone, 5,
+			[]string{"1::9e-01:Und", "2:-CANDIDATE:5e-02:Vnd", "3:-CANDIDATE:3e-02:And"},
+			[]string{"1::9e-01:U"}},
+		{"ocr", keepOCR, 0,
+			[]string{"1::9e-01:Und", "2:OCR:0.8:Vnd"},
+			[]string{"1::9e-01:U", "2:OCR::V"}},
+		{"ocr/alts=2", keepOCR, 2,
+			[]string{"1::9e-01:Und", "2:OCR:0.8:Vnd", "3:-CANDIDATE:5e-02:Vnd", "4:-CANDIDATE:3e-02:And"},
+			[]string{"1::9e-01:U", "2:OCR::V"}},
+		{"all", keepAll, 0,
+			[]string{"1::9e-01:Und", "2::0.8:Vnd", "3:::Und"},
+			[]string{"1::9e-01:U", "2:::V"}},
+		{"all/alts=2", keepAll, 2,
+			[]string{"1::9e-01:Und", "2:-CANDIDATE:5e-02:Vnd", "3:-CANDIDATE:3e-02:And", "4::0.8:Vnd", "5:::Und"},
+			[]string{"1::9e-01:U", "2:::V"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader(testWord))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			info := &stok{
+				Stok: internal.Stok{ID: "OCR_0001_w1", OCR: "vnd", Sug: "und", Conf: .9, Cor: true},
+				rankings: []apoco.Ranking{
+					// The first candidate equals the correction and is skipped.
+					{Candidate: &gofiler.Candidate{Suggestion: "und"}, Prob: .9},
+					{Candidate: &gofiler.Candidate{Suggestion: "vnd"}, Prob: .05},
+					{Candidate: &gofiler.Candidate{Suggestion: "and"}, Prob: .03},
+				},
+			}
+			cor := metsCorrector{
+				stoks: stokMap{file: {pagexml.TokenID(file, "w1"): info}},
+				ofg:   "OUT",
+				keep:  tc.keep,
+				alts:  tc.alts,
+			}
+			word := xmlquery.FindOne(doc, "/Word")
+			if err := cor.correctWord(word, file); err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if got := textEquivs(word); !reflect.DeepEqual(got, tc.word) {
+				t.Errorf("expected word TextEquivs %v; got %v", tc.word, got)
+			}
+			glyph := xmlquery.FindOne(word, "./Glyph[@id='g1']")
+			if got := textEquivs(glyph); !reflect.DeepEqual(got, tc.glyph) {
+				t.Errorf("expected glyph TextEquivs %v; got %v", tc.glyph, got)
+			}
+			if want := "OUT_0001_OCR_0001_w1"; info.ID != want {
+				t.Errorf("expected id %s; got %s", want, info.ID)
+			}
+		})
+	}
+}
+
+const testMETS = `<?xml version="1.0" encoding="UTF-8"?>
+<mets:mets xmlns:mets="http://www.loc.gov/METS/" xmlns:xlink="http://www.w3.org/1999/xlink">
+<mets:fileSec>
+<mets:fileGrp USE="OCR">
+<mets:file MIMETYPE="application/vnd.prima.page+xml" ID="OCR_0001">
+<mets:FLocat LOCTYPE="OTHER" OTHERLOCTYPE="FILE" xlink:href="OCR/OCR_0001.xml"/>
+</mets:file>
+</mets:fileGrp>
+</mets:fileSec>
+<mets:structMap TYPE="PHYSICAL">
+<mets:div TYPE="physSequence" ID="physroot">
+<mets:div TYPE="page" ORDER="1" ID="PHYS_0001">
+<mets:fptr FILEID="OCR_0001"/>
+</mets:div>
+</mets:div>
+</mets:structMap>
+</mets:mets>`
+
+func TestMETSCorrector(t *testing.T) {
+	dir := t.TempDir()
+	page := `<PcGts><Metadata/><Page><TextRegion id="r1"><TextLine id="l1">` + testWord +
+		`<TextEquiv index="1"><Unicode>Vnd</Unicode></TextEquiv></TextLine>` +
+		`<TextEquiv index="1"><Unicode>Vnd</Unicode></TextEquiv></TextRegion></Page></PcGts>`
+	if err := os.MkdirAll(filepath.Join(dir, "OCR"), 0777); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "OCR", "OCR_0001.xml"), []byte(page), 0666); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mets.xml"), []byte(testMETS), 0666); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	file := filepath.Join(dir, "OCR", "OCR_0001.xml")
+	stoks := stokMap{file: {pagexml.TokenID(file, "w1"): &stok{
+		Stok: internal.Stok{ID: "OCR_0001_w1", OCR: "vnd", Sug: "und", Conf: .9, Cor: true},
+		rankings: []apoco.Ranking{
+			{Candidate: &gofiler.Candidate{Suggestion: "und"}, Prob: .9},
+			{Candidate: &gofiler.Candidate{Suggestion: "vnd"}, Prob: .05},
+		},
+	}}}
+	cor, err := newMETSCorrector(filepath.Join(dir, "mets.xml"), "OUT", "", stoks, "OCR")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	cor.keep, cor.alts = keepOCR, 1
+	if err := cor.correct(); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	is, err := os.Open(filepath.Join(dir, "OUT", "OUT_0001.xml"))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	defer is.Close()
+	doc, err := xmlquery.Parse(is)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := []string{"1::9e-01:Und", "2:OCR:0.8:Vnd", "3:-CANDIDATE:5e-02:Vnd"}
+	if got := textEquivs(xmlquery.FindOne(doc, "//Word")); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	want = []string{"1:::Und"}
+	if got := textEquivs(xmlquery.FindOne(doc, "//TextLine")); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
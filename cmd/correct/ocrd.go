@@ -0,0 +1,304 @@
+package correct
+
+import (
+	_ "embed" // Embed ocrd-tool.json.
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
+	"github.com/spf13/cobra"
+)
+
+//go:embed ocrd-tool.json
+var ocrdTool []byte
+
+// OCRDExecutable defines the name of the OCR-D processor.  If apoco
+// is called using this name, it runs the OCR-D processor interface.
+const OCRDExecutable = "ocrd-apoco-correct"
+
+var ocrdFlags = struct {
+	ifgs, overrides             []string // overrides are parsed by ocrdOverrides
+	ofg, mets, wd, pages, param string
+	dump, overwrite, version    bool
+}{}
+
+// OCRDCmd defines the OCR-D processor interface of the apoco correct
+// command.
+var OCRDCmd = &cobra.Command{
+	Use:   "ocrd [-P KEY VALUE]...",
+	Short: "Run the correction as OCR-D processor (" + OCRDExecutable + ")",
+	Long: `Run the correction as OCR-D processor (` + OCRDExecutable + `).
+
+Parameters are read from the parameter file or json string (-p) and
+can be overwritten using -P KEY VALUE.  Nested parameters are set
+using dotted keys (e.g. -P profiler.exe /usr/bin/profiler).  Values
+are interpreted as json if possible and as strings otherwise.  The
+language models are always read from the model.`,
+	Run: runOCRD,
+	// The KEY VALUE pairs of -P are parsed manually (see runOCRD).
+	DisableFlagParsing: true,
+}
+
+func init() {
+	OCRDCmd.Flags().StringVarP(&ocrdFlags.mets, "mets", "m", "mets.xml",
+		"set path to the mets file (relative to the working directory)")
+	OCRDCmd.Flags().StringVarP(&ocrdFlags.wd, "working-dir", "w", "",
+		"set the working directory")
+	OCRDCmd.Flags().StringSliceVarP(&ocrdFlags.ifgs, "input-file-grp", "I", nil,
+		"set input file groups")
+	OCRDCmd.Flags().StringVarP(&ocrdFlags.ofg, "output-file-grp", "O", "",
+		"set output file group")
+	OCRDCmd.Flags().StringVarP(&ocrdFlags.pages, "page-id", "g", "",
+		"only process the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
+	OCRDCmd.Flags().StringVarP(&ocrdFlags.param, "parameter", "p", "",
+		"set the parameter file or json string")
+	OCRDCmd.Flags().StringArrayVarP(&ocrdFlags.overrides, "param-override", "P", nil,
+		"overwrite the parameter KEY with VALUE (-P KEY VALUE)")
+	OCRDCmd.Flags().BoolVar(&ocrdFlags.overwrite, "overwrite", false,
+		"overwrite existing files in the output file group")
+	OCRDCmd.Flags().BoolVarP(&ocrdFlags.dump, "dump-json", "J", false,
+		"print the ocrd-tool.json and exit")
+	OCRDCmd.Flags().BoolVarP(&ocrdFlags.version, "version", "V", false,
+		"print the version and exit")
+}
+
+func runOCRD(cmd *cobra.Command, args []string) {
+	args, overrides, err := ocrdOverrides(args)
+	chk(err)
+	chk(cmd.Flags().Parse(args))
+	// Run the root's pre run hook again with the parsed flags.
+	if pre := cmd.Root().PersistentPreRun; pre != nil {
+		pre(cmd, cmd.Flags().Args())
+	}
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		chk(cmd.Help())
+		return
+	}
+	if cmd.Flags().NArg() > 0 {
+		chk(fmt.Errorf("invalid argument(s): %s", strings.Join(cmd.Flags().Args(), " ")))
+	}
+	if ocrdFlags.dump {
+		fmt.Println(dumpOCRDTool())
+		return
+	}
+	if ocrdFlags.version {
+		fmt.Printf("Version %s, %s\n", internal.Version, OCRDExecutable)
+		return
+	}
+	if len(ocrdFlags.ifgs) == 0 || ocrdFlags.ofg == "" {
+		chk(fmt.Errorf("missing input or output file group"))
+	}
+	params, err := ocrdSetup(ocrdFlags.param, ocrdFlags.wd, overrides)
+	chk(err)
+	chk(checkOutputFileGrp(ocrdFlags.mets, ocrdFlags.ofg, ocrdFlags.pages, ocrdFlags.overwrite))
+	str, err := json.Marshal(params)
+	chk(err)
+	// Map the parameters onto apoco correct.
+	flags.params = string(str)
+	flags.mets, flags.ifgs, flags.ofg = ocrdFlags.mets, ocrdFlags.ifgs, ocrdFlags.ofg
+	flags.pages = ocrdFlags.pages
+	flags.exts = []string{".xml"}
+	flags.correct = true
+	flags.cands = -1
+	chk(ocrdParam(params, "profile", &flags.profile))
+	chk(ocrdParam(params, "lines", &flags.lines))
+	chk(ocrdParam(params, "words", &flags.words))
+	chk(ocrdParam(params, "keep", &flags.keep))
+	chk(ocrdParam(params, "alternatives", &flags.alts))
+	chk(ocrdParam(params, "dropGlyphs", &flags.drop))
+	flags.notes = []mets.Note{
+		{Option: "parameter", Value: string(str)},
+		{Option: "input-file-grp", Value: strings.Join(ocrdFlags.ifgs, ",")},
+		{Option: "output-file-grp", Value: ocrdFlags.ofg},
+	}
+	if ocrdFlags.pages != "" {
+		flags.notes = append(flags.notes, mets.Note{Option: "page-id", Value: ocrdFlags.pages})
+	}
+	run(nil, nil)
+}
+
+// dumpOCRDTool returns the embedded ocrd-tool.json with the current
+// version of apoco.
+func dumpOCRDTool() string {
+	version := fmt.Sprintf("%q: %q", "version", strings.TrimPrefix(internal.Version, "v"))
+	return strings.Replace(strings.TrimSpace(string(ocrdTool)), `"version": "0.0.0"`, version, 1)
+}
+
+type ocrdParameter struct {
+	Type     string      `json:"type"`
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default"`
+}
+
+func ocrdParameterSpecs() (map[string]ocrdParameter, error) {
+	var tool struct {
+		Tools map[string]struct {
+			Parameters map[string]ocrdParameter `json:"parameters"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(ocrdTool, &tool); err != nil {
+		return nil, err
+	}
+	return tool.Tools[OCRDExecutable].Parameters, nil
+}
+
+// ocrdOverrides removes the -P KEY VALUE pairs from the given
+// arguments.  It returns the remaining arguments and the overrides as
+// a list of alternating keys and values.
+func ocrdOverrides(args []string) ([]string, []string, error) {
+	var rest, overrides []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-P", "--param-override":
+			if i+2 >= len(args) {
+				return nil, nil, fmt.Errorf("invalid parameter override: use -P KEY VALUE")
+			}
+			overrides = append(overrides, args[i+1], args[i+2])
+			i += 2
+		case "--":
+			return append(rest, args[i:]...), overrides, nil
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, overrides, nil
+}
+
+// ocrdSetup reads the parameters (see ocrdParameters) and changes
+// into the given working directory.  The parameters are read before
+// changing the working directory, since the parameter file is given
+// relative to the caller.
+func ocrdSetup(param, wd string, overrides []string) (map[string]interface{}, error) {
+	params, err := ocrdParameters(param, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if wd != "" {
+		if err := os.Chdir(wd); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// ocrdParameters reads the parameters from the given parameter file
+// or json string and applies the given overrides (alternating keys
+// and their according values).  The resulting parameters are checked
+// against the parameters of the ocrd-tool.json and missing parameters
+// are set to their defaults.
+func ocrdParameters(param string, overrides []string) (map[string]interface{}, error) {
+	fail := func(err error) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("read parameters: %v", err)
+	}
+	params := make(map[string]interface{})
+	if param != "" {
+		data := []byte(param)
+		if !strings.HasPrefix(param, "{") {
+			var err error
+			if data, err = os.ReadFile(param); err != nil {
+				return fail(err)
+			}
+		}
+		if err := json.Unmarshal(data, &params); err != nil {
+			return fail(err)
+		}
+	}
+	if len(overrides)%2 != 0 {
+		return fail(fmt.Errorf("invalid parameter overrides: use -P KEY VALUE"))
+	}
+	for i := 0; i < len(overrides); i += 2 {
+		var val interface{}
+		if err := json.Unmarshal([]byte(overrides[i+1]), &val); err != nil {
+			val = overrides[i+1]
+		}
+		if err := setOCRDParam(params, strings.Split(overrides[i], "."), val); err != nil {
+			return fail(err)
+		}
+	}
+	specs, err := ocrdParameterSpecs()
+	if err != nil {
+		return fail(err)
+	}
+	for key := range params {
+		if _, ok := specs[key]; !ok {
+			return fail(fmt.Errorf("invalid parameter: %s", key))
+		}
+	}
+	for key, spec := range specs {
+		if _, ok := params[key]; ok {
+			continue
+		}
+		if spec.Required {
+			return fail(fmt.Errorf("missing parameter: %s", key))
+		}
+		if spec.Default != nil {
+			params[key] = spec.Default
+		}
+	}
+	return params, nil
+}
+
+func setOCRDParam(params map[string]interface{}, keys []string, val interface{}) error {
+	if len(keys) == 1 {
+		params[keys[0]] = val
+		return nil
+	}
+	if _, ok := params[keys[0]]; !ok {
+		params[keys[0]] = make(map[string]interface{})
+	}
+	sub, ok := params[keys[0]].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid parameter: %s is not an object", keys[0])
+	}
+	return setOCRDParam(sub, keys[1:], val)
+}
+
+// ocrdParam sets dest to the value of the given parameter if it
+// exists.  Dest must be a pointer to string, int or bool.
+func ocrdParam(params map[string]interface{}, key string, dest interface{}) error {
+	val, ok := params[key]
+	if !ok {
+		return nil
+	}
+	var good bool
+	switch dest := dest.(type) {
+	case *string:
+		*dest, good = val.(string)
+	case *bool:
+		*dest, good = val.(bool)
+	case *int:
+		var f float64
+		f, good = val.(float64)
+		*dest = int(f)
+	default:
+		panic("bad type")
+	}
+	if !good {
+		return fmt.Errorf("invalid parameter %s: %v", key, val)
+	}
+	return nil
+}
+
+// checkOutputFileGrp returns an error if the output file group
+// already contains files for the selected pages and the files should
+// not be overwritten.
+func checkOutputFileGrp(name, ofg, pages string, overwrite bool) error {
+	if overwrite {
+		return nil
+	}
+	m, err := mets.Open(name)
+	if err != nil {
+		return err
+	}
+	ids, err := m.PageIDs(pages)
+	if err != nil {
+		return err
+	}
+	if len(m.FindFlocats(ofg, ids...)) > 0 {
+		return fmt.Errorf("output file group %s already exists (use --overwrite)", ofg)
+	}
+	return nil
+}
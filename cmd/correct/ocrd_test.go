@@ -0,0 +1,162 @@
+package correct
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOCRDOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		args, rest, overrides []string
+		err                   bool
+	}{
+		{[]string{"-I", "OCR", "-O", "COR"}, []string{"-I", "OCR", "-O", "COR"}, nil, false},
+		{
+			[]string{"-P", "nocr", "2", "-I", "OCR", "--param-override", "profiler.exe", "/bin/profiler", "-P", "keep", "ocr"},
+			[]string{"-I", "OCR"},
+			[]string{"nocr", "2", "profiler.exe", "/bin/profiler", "keep", "ocr"},
+			false,
+		},
+		{[]string{"-P", "nocr", "-I"}, nil, []string{"nocr", "-I"}, false},
+		{[]string{"-I", "OCR", "-P", "nocr"}, nil, nil, true},
+		{[]string{"-P"}, nil, nil, true},
+		{[]string{"-I", "OCR", "--", "-P", "nocr", "2"}, []string{"-I", "OCR", "--", "-P", "nocr", "2"}, nil, false},
+	} {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			rest, overrides, err := ocrdOverrides(tc.args)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if !reflect.DeepEqual(rest, tc.rest) {
+				t.Errorf("expected rest %q; got %q", tc.rest, rest)
+			}
+			if !reflect.DeepEqual(overrides, tc.overrides) {
+				t.Errorf("expected overrides %q; got %q", tc.overrides, overrides)
+			}
+		})
+	}
+}
+
+func TestOCRDParameters(t *testing.T) {
+	const param = `{"model":"model.bin","nocr":2}`
+	for _, tc := range []struct {
+		name, param string
+		overrides   []string
+		want        map[string]interface{} // checked entries
+		err         bool
+	}{
+		{"defaults", param, nil, map[string]interface{}{
+			"model": "model.bin", "nocr": 2.0, "keep": "none", "alternatives": 0.0, "lines": false,
+		}, false},
+		{"overrides", param, []string{"nocr", "3", "lines", "true", "keep", "ocr", "model", "other.bin"},
+			map[string]interface{}{
+				"model": "other.bin", "nocr": 3.0, "keep": "ocr", "lines": true,
+			}, false},
+		{"json string", param, []string{"profile", `"1"`}, map[string]interface{}{"profile": "1"}, false},
+		{"nested", param, []string{"profiler.exe", "/bin/profiler", "profiler.config", "german.ini"},
+			map[string]interface{}{"profiler": map[string]interface{}{
+				"exe": "/bin/profiler", "config": "german.ini",
+			}}, false},
+		{"only overrides", "", []string{"model", "model.bin", "nocr", "1"},
+			map[string]interface{}{"model": "model.bin", "nocr": 1.0}, false},
+		{"unknown key", param, []string{"foo", "1"}, nil, true},
+		{"unknown key in parameters", `{"model":"model.bin","nocr":2,"foo":1}`, nil, nil, true},
+		{"not an object", param, []string{"model.exe", "x"}, nil, true},
+		{"missing required", `{"model":"model.bin"}`, nil, nil, true},
+		{"missing value", param, []string{"nocr"}, nil, true},
+		{"invalid json", `{"model"`, nil, nil, true},
+		{"missing file", "does-not-exist.json", nil, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			params, err := ocrdParameters(tc.param, tc.overrides)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			for key, want := range tc.want {
+				if got := params[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("expected %s=%v (%T); got %v (%T)", key, want, want, got, got)
+				}
+			}
+		})
+	}
+}
+
+func TestOCRDParam(t *testing.T) {
+	params := map[string]interface{}{"s": "str", "b": true, "i": 3.0}
+	var s, es string
+	var b, eb bool
+	var i, ei int
+	for _, tc := range []struct {
+		key  string
+		dest interface{}
+		err  bool
+	}{
+		{"s", &s, false},
+		{"b", &b, false},
+		{"i", &i, false},
+		{"missing", &s, false},
+		{"s", &eb, true},
+		{"b", &ei, true},
+		{"i", &es, true},
+	} {
+		if err := ocrdParam(params, tc.key, tc.dest); (err != nil) != tc.err {
+			t.Errorf("%s: expected error %t; got %v", tc.key, tc.err, err)
+		}
+	}
+	if s != "str" || !b || i != 3 {
+		t.Errorf("invalid parameters: %q %t %d", s, b, i)
+	}
+}
+
+func TestOCRDSetup(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	defer os.Chdir(cwd)
+	// The parameter file is relative to the caller's directory and
+	// the working directory is changed after reading it.
+	dir := t.TempDir()
+	ws := filepath.Join(dir, "ws")
+	if err := os.Mkdir(ws, 0777); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "params.json"), []byte(`{"model":"model.bin","nocr":2}`), 0666); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	params, err := ocrdSetup("params.json", "ws", []string{"nocr", "3"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if params["model"] != "model.bin" || params["nocr"] != 3.0 {
+		t.Errorf("invalid parameters: %v", params)
+	}
+	got, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if want, _ := filepath.EvalSymlinks(ws); got != want && got != ws {
+		t.Errorf("expected working directory %s; got %s", ws, got)
+	}
+	if _, err := ocrdSetup("params.json", "ws", nil); err == nil {
+		t.Errorf("expected an error for a missing parameter file in the working directory")
+	}
+}
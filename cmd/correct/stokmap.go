@@ -1,6 +1,7 @@
 package correct
 
 import (
+	"sort"
 	"strings"
 	"sync"
 
@@ -21,6 +22,20 @@ func (m stokMap) numberOfTokens() int {
 	return sum
 }
 
+// sorted returns the stoks in the order of their tokens.
+func (m stokMap) sorted() []*stok {
+	ret := make([]*stok, 0, m.numberOfTokens())
+	for _, ids := range m {
+		for _, info := range ids {
+			ret = append(ret, info)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].order < ret[j].order
+	})
+	return ret
+}
+
 type stok struct {
 	internal.Stok
 	rankings []apoco.Ranking
@@ -57,6 +72,19 @@ func candidates2string(cs []gofiler.Candidate, max int) string {
 	return strings.Join(strs, "/")
 }
 
+// parts returns the stoks of the parts of a joined hyphenated token or
+// the stok of the token if it is not joined.
+func (m stokMap) parts(t apoco.T) []*stok {
+	if len(t.Parts) == 0 {
+		return []*stok{m.get(t)}
+	}
+	ret := make([]*stok, len(t.Parts))
+	for i := range t.Parts {
+		ret[i] = m.get(t.Parts[i])
+	}
+	return ret
+}
+
 func (m stokMap) get(t apoco.T) *stok {
 	stokMapLock.Lock()
 	defer stokMapLock.Unlock()
@@ -0,0 +1,117 @@
+package correct
+
+import (
+	"context"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"github.com/spf13/cobra"
+)
+
+var voteFlags = struct {
+	ifgs, exts                                   []string
+	ofg, mets, params, profile, suf, keep, pages string
+	nocr                                         int
+	alpha, nullConf, lex                         float64
+	gt, correct, lines, words, drop              bool
+}{}
+
+// VoteCmd defines the apoco vote command.
+var VoteCmd = &cobra.Command{
+	Use:   "vote [DIRS...]",
+	Short: "Correct documents by voting over the aligned OCRs",
+	Long: `Correct documents by voting over the aligned OCRs.
+
+Each word is scored using alpha*freq + (1-alpha)*conf, where freq is
+the relative number of OCRs voting for the word and conf is the mean
+confidence of these OCRs.  Only the master OCR uses character
+confidences: its confidence is the mean of its character
+confidences.  The support OCRs always use the null confidence (see
+--null-conf).  If a lexicon weight is given, lexicon entries
+(according to the profile) get the additional weight.
+
+As with apoco correct, short tokens and lexicon entries are marked
+and not corrected.  No model is used; the output (stoks or corrected
+files) is the same as for apoco correct.`,
+	Run: runVote,
+}
+
+func init() {
+	VoteCmd.Flags().StringSliceVarP(&voteFlags.ifgs, "input-file-grp", "I",
+		nil, "set input file groups")
+	VoteCmd.Flags().StringSliceVarP(&voteFlags.exts, "extensions", "e",
+		[]string{".xml"}, "set input file extensions")
+	VoteCmd.Flags().StringVarP(&voteFlags.ofg, "output-file-grp", "O",
+		"", "set output file group")
+	VoteCmd.Flags().StringVar(&voteFlags.pages, "page-id", "",
+		"only process the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
+	VoteCmd.Flags().StringVarP(&voteFlags.mets, "mets", "m",
+		"mets.xml", "set path to the mets file")
+	VoteCmd.Flags().StringVarP(&voteFlags.params, "parameter", "p",
+		"config.toml", "set path to the configuration file")
+	VoteCmd.Flags().StringVarP(&voteFlags.profile, "profile", "f",
+		"", "set external profile file")
+	VoteCmd.Flags().StringVarP(&voteFlags.suf, "suffix", "s",
+		".cor.txt", "set the suffix for correction snippet files")
+	VoteCmd.Flags().IntVarP(&voteFlags.nocr, "nocr", "n",
+		0, "set nocr (overwrites setting in the configuration file)")
+	VoteCmd.Flags().Float64VarP(&voteFlags.alpha, "alpha", "A",
+		0.5, "set the weight of the vote frequencies against the confidences")
+	VoteCmd.Flags().Float64VarP(&voteFlags.nullConf, "null-conf", "N",
+		0.5, "set the confidence of the support OCRs")
+	VoteCmd.Flags().Float64VarP(&voteFlags.lex, "lex", "x",
+		0, "set the additional weight of lexicon entries")
+	VoteCmd.Flags().BoolVarP(&voteFlags.gt, "gt", "g", false, "enable ground-truth data")
+	VoteCmd.Flags().BoolVarP(&voteFlags.correct, "correct", "C", false, "do not output stoks; correct files directly")
+	VoteCmd.Flags().BoolVarP(&voteFlags.lines, "lines", "L", false, "tokenize page xml files on the line level (ignore words)")
+	VoteCmd.Flags().BoolVarP(&voteFlags.words, "words", "W", false, "insert generated words into corrected lines (see --lines)")
+	VoteCmd.Flags().StringVarP(&voteFlags.keep, "keep", "k", keepNone,
+		"set policy for original TextEquivs of corrected words (none, ocr or all)")
+	VoteCmd.Flags().BoolVarP(&voteFlags.drop, "drop-glyphs", "G", false, "remove glyphs of corrected words (do not update them)")
+}
+
+func runVote(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(voteFlags.params)
+	chk(err)
+	internal.UpdateInConfig(&c.Nocr, voteFlags.nocr)
+	internal.UpdateInConfig(&c.GT, voteFlags.gt)
+	// Map the flags onto apoco correct.
+	flags.ifgs, flags.exts, flags.ofg = voteFlags.ifgs, voteFlags.exts, voteFlags.ofg
+	flags.mets, flags.suf, flags.keep = voteFlags.mets, voteFlags.suf, voteFlags.keep
+	flags.pages, flags.correct, flags.cands = voteFlags.pages, voteFlags.correct, -1
+	flags.lines, flags.words, flags.drop = voteFlags.lines, voteFlags.words, voteFlags.drop
+	stoks := make(stokMap)
+	p := internal.Piper{
+		IFGS:     flags.ifgs,
+		METS:     flags.mets,
+		Exts:     flags.exts,
+		Dirs:     args,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		Lines:    flags.lines,
+		Pages:    flags.pages,
+	}
+	chk(p.Pipe(
+		context.Background(),
+		apoco.FilterBad(c.Nocr),
+		register(stoks),
+		internal.JoinHyphenations(c),
+		apoco.Normalize(),
+		addTokens(stoks, voteFlags.gt),
+		filterShort(stoks),
+		connectProfile(c, nil, voteFlags.profile),
+		filterLex(stoks),
+		apoco.ConnectVotes(apoco.Voter{
+			Alpha:    voteFlags.alpha,
+			NullConf: voteFlags.nullConf,
+			Lex:      voteFlags.lex,
+		}, c.Nocr),
+		correct(stoks),
+	))
+	apoco.Log("correcting %d pages (%d tokens)", len(stoks), stoks.numberOfTokens())
+	// Add additional arguments to the input file groups.
+	flags.ifgs = append(args, flags.ifgs...)
+	cor, err := mkcorrector(stoks)
+	chk(err)
+	chk(cor.correct())
+}
@@ -20,10 +20,11 @@ var Cmd = &cobra.Command{
 }
 
 var flags = struct {
-	extensions            []string
-	parameter, model, out string
-	nocr, bufs            int
-	cache, alev, lex      bool
+	extensions                              []string
+	parameter, model, out                   string
+	nocr, bufs                              int
+	minAlign                                float64
+	cache, alev, amsa, ocands, hyphens, lex bool
 }{}
 
 const bufs int = 64 * 1024
@@ -42,7 +43,15 @@ func init() {
 		"enable caching of profiles (overwrites the setting in the configuration file)")
 	Cmd.PersistentFlags().BoolVarP(&flags.alev, "alignlev", "v", false,
 		"align using Levenshtein (matrix) alignment")
+	Cmd.PersistentFlags().BoolVarP(&flags.amsa, "alignmsa", "S", false,
+		"align using multiple sequence alignment (overwrites --alignlev)")
+	Cmd.PersistentFlags().BoolVarP(&flags.ocands, "ocrcands", "u", false,
+		"use the support OCR tokens as additional candidates")
+	Cmd.PersistentFlags().BoolVarP(&flags.hyphens, "hyphens", "H", false,
+		"join hyphenated tokens at the end of lines")
 	Cmd.PersistentFlags().BoolVarP(&flags.lex, "lex", "x", false, "operate on lexical tokens only")
+	Cmd.PersistentFlags().Float64VarP(&flags.minAlign, "min-alignment", "A", 0,
+		"skip lines with an alignment agreement less than the given value")
 	Cmd.PersistentFlags().StringVarP(&flags.out, "out", "o", "out.csv", "set output file")
 
 	// Subcommands
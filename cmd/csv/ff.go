@@ -26,6 +26,7 @@ func ffRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
 
 	profile_path := strings.Replace(args[0], "corpus", "profiles-c", -1)
 	//	profile_path :=  strings.Replace(args[0],"corpus","profiles-b",-1)
@@ -46,6 +47,9 @@ func ffRun(_ *cobra.Command, args []string) {
 		Exts:     flags.extensions,
 		Dirs:     args,
 		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		MinAlign: flags.minAlign,
+		Nocr:     c.Nocr,
 	}
 	chk(p.Pipe(
 		context.Background(),
@@ -30,12 +30,16 @@ func msRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
 	m, err := internal.ReadModel(c.Model, c.LM, true)
 	chk(err)
 	p := internal.Piper{
 		Exts:     flags.extensions,
 		Dirs:     args,
 		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		MinAlign: flags.minAlign,
+		Nocr:     c.Nocr,
 	}
 	chk(p.Pipe(
 		context.Background(),
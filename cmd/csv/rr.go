@@ -4,9 +4,6 @@ import (
 	"context"
 
 	"git.sr.ht/~flobar/apoco/cmd/internal"
-	"git.sr.ht/~flobar/apoco/pkg/apoco"
-	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
-	"github.com/finkf/gofiler"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +22,9 @@ func rrRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
+	internal.UpdateInConfig(&c.OCRCands, flags.ocands)
+	internal.UpdateInConfig(&c.Hyphens, flags.hyphens)
 	internal.UpdateInConfig(&c.Lex, flags.lex)
 
 	m, err := internal.ReadModel(c.Model, c.LM, true)
@@ -34,23 +34,13 @@ func rrRun(_ *cobra.Command, args []string) {
 		Exts:     flags.extensions,
 		Dirs:     args,
 		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		MinAlign: flags.minAlign,
+		Nocr:     c.Nocr,
 	}
 	chk(p.Pipe(
 		context.Background(),
-		apoco.FilterBad(c.Nocr+1), // at least n ocr + ground truth
-		apoco.Normalize(),
-		apoco.FilterShort(4),
-		apoco.ConnectLanguageModel(m.LM),
-		apoco.ConnectUnigrams(),
-		internal.ConnectProfile(c, "-profile.json.gz"),
-		internal.FilterLex(c),
-		apoco.ConnectCandidates(),
-		csv(c.RR.Features, c.Nocr, rrGT),
+		append(internal.CandidateStreams(c, m), csv(c.RR.Features, c.Nocr, internal.RRGT))...,
 	))
 	chk(m.Write(c.Model))
 }
-
-func rrGT(t apoco.T) (float64, bool) {
-	candidate := t.Payload.(*gofiler.Candidate)
-	return ml.Bool(candidate.Suggestion == t.Tokens[len(t.Tokens)-1]), true
-}
@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// evaluation holds the global stats of an evaluation and its
+// breakdowns by document (group) and by page (file).
+type evaluation struct {
+	ml.Confusion
+	docs, pages breakdown
+}
+
+// breakdown holds the stats of multiple documents or pages in the
+// order of their first occurrence.
+type breakdown struct {
+	stats map[string]*ml.Confusion
+	order []string
+}
+
+func (b *breakdown) get(name string) *ml.Confusion {
+	if b.stats == nil {
+		b.stats = make(map[string]*ml.Confusion)
+	}
+	c, ok := b.stats[name]
+	if !ok {
+		c = &ml.Confusion{}
+		b.stats[name] = c
+		b.order = append(b.order, name)
+	}
+	return c
+}
+
+func (e *evaluation) eval(p ml.Predictor, t float64, xs, ys []float64, ts []apoco.T) {
+	ylen := len(ys)
+	x := mat.NewDense(ylen, len(xs)/ylen, xs)
+	ps := p.Predict(x)
+	ml.ApplyThreshold(ps, t)
+	for i := 0; i < ylen; i++ {
+		e.add(ts[i], ys[i], ps.AtVec(i))
+	}
+}
+
+// add adds the prediction p for the token t with the gold value y to
+// the global stats and to the stats of its document and page.
+func (e *evaluation) add(t apoco.T, y, p float64) typ {
+	e.docs.get(docName(t)).Add(y, p)
+	e.pages.get(t.File).Add(y, p)
+	e.Confusion.Add(y, p)
+	return classify(y, p)
+}
+
+func docName(t apoco.T) string {
+	if t.Document == nil {
+		return ""
+	}
+	return t.Document.Group
+}
+
+// statsData holds the (exported) stats data for the json output.
+type statsData struct {
+	Name                  string
+	TP, FP, TN, FN        int
+	Precision, Recall, F1 float64
+	Z                     float64 // Z-score of the f1 score (documents only).
+	Outlier               bool
+}
+
+func makeStatsData(name string, c ml.Confusion) statsData {
+	return statsData{
+		Name:      name,
+		TP:        c.TP,
+		FP:        c.FP,
+		TN:        c.TN,
+		FN:        c.FN,
+		Precision: c.Precision(),
+		Recall:    c.Recall(),
+		F1:        c.F1(),
+	}
+}
+
+func (b *breakdown) data() []statsData {
+	ret := make([]statsData, len(b.order))
+	for i, name := range b.order {
+		ret[i] = makeStatsData(name, *b.stats[name])
+	}
+	return ret
+}
+
+// outliers calculates the z-scores of the documents' f1 scores and
+// marks all documents as outliers whose absolute z-score is at least
+// the given threshold.  A threshold <= 0 disables the outlier
+// detection.
+func outliers(docs []statsData, threshold float64) {
+	if threshold <= 0 || len(docs) < 2 {
+		return
+	}
+	f1s := make([]float64, len(docs))
+	for i := range docs {
+		f1s[i] = docs[i].F1
+	}
+	mean, std := stat.MeanStdDev(f1s, nil)
+	if std == 0 {
+		return
+	}
+	for i := range docs {
+		docs[i].Z = (docs[i].F1 - mean) / std
+		docs[i].Outlier = math.Abs(docs[i].Z) >= threshold
+	}
+}
+
+type evaluationData struct {
+	Type      string
+	Nocr      int
+	Total     statsData
+	Documents []statsData
+	Pages     []statsData
+}
+
+func (e *evaluation) data(typ string, nocr int) evaluationData {
+	ret := evaluationData{
+		Type:      typ,
+		Nocr:      nocr,
+		Total:     makeStatsData("", e.Confusion),
+		Documents: e.docs.data(),
+		Pages:     e.pages.data(),
+	}
+	outliers(ret.Documents, flags.outliers)
+	return ret
+}
+
+// print prints the evaluation.  The breakdowns are printed if
+// requested or if outliers should be flagged.
+func (e *evaluation) print(out io.Writer, typ string, nocr int) error {
+	data := e.data(typ, nocr)
+	if flags.json {
+		return json.NewEncoder(out).Encode(data)
+	}
+	if err := printConfusion(out, e.Confusion, typ, nocr); err != nil {
+		return err
+	}
+	f := formater{out: out}
+	if flags.breakdown {
+		for _, d := range data.Documents {
+			f.printStats(typ, nocr, "doc", d)
+		}
+		for _, d := range data.Pages {
+			f.printStats(typ, nocr, "page", d)
+		}
+	}
+	for _, d := range data.Documents {
+		if d.Outlier {
+			f.printf("%s/%d outlier doc=%s f1 %f z %f\n", typ, nocr, d.Name, d.F1, d.Z)
+		}
+	}
+	return f.err
+}
+
+func (f *formater) printStats(typ string, nocr int, kind string, d statsData) {
+	f.printf("%s/%d %s=%s tp %d fp %d tn %d fn %d pr %f re %f f1 %f\n",
+		typ, nocr, kind, d.Name, d.TP, d.FP, d.TN, d.FN, d.Precision, d.Recall, d.F1)
+}
@@ -0,0 +1,173 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/spf13/cobra"
+)
+
+// curveCmd defines the apoco eval curve command.
+var curveCmd = &cobra.Command{
+	Use:   "curve CSV...",
+	Short: "Print learning curves of a model",
+	Long: `
+Prints the learning curve of the model of the given type (rr or dm).
+Each CSV file (see apoco csv) holds the training instances of one
+document.  The documents are randomly split into a fixed held-out set
+and a set of training documents.  New models are trained on
+increasing fractions of the training documents and are evaluated on
+the held-out documents.  The metrics are printed for each number of
+training tokens either as table or as data for gnuplot.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  curveRun,
+}
+
+var curveFlags = struct {
+	typ, format string
+	test        float64
+	seed        int64
+	steps       int
+}{}
+
+func init() {
+	curveCmd.Flags().StringVarP(&curveFlags.typ, "type", "t", "rr",
+		"set the type of the model (rr or dm)")
+	curveCmd.Flags().StringVarP(&curveFlags.format, "format", "f", "table",
+		"set output format (table or dat)")
+	curveCmd.Flags().Float64VarP(&curveFlags.test, "test", "T", .2,
+		"set the fraction of held-out documents")
+	curveCmd.Flags().Int64VarP(&curveFlags.seed, "seed", "s", 1,
+		"set the seed for the split and the order of the documents")
+	curveCmd.Flags().IntVarP(&curveFlags.steps, "steps", "k", 5,
+		"set the number of training steps")
+}
+
+func curveRun(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(flags.parameter)
+	chk(err)
+
+	internal.UpdateInConfig(&c.Nocr, flags.nocr)
+
+	var tc internal.TrainingConfig
+	switch curveFlags.typ {
+	case "rr":
+		tc = c.RR
+	case "dm":
+		tc = c.DM.TrainingConfig
+	default:
+		chk(fmt.Errorf("bad type: %s", curveFlags.typ))
+	}
+	switch curveFlags.format {
+	case "table", "dat":
+	default:
+		chk(fmt.Errorf("bad format: %s", curveFlags.format))
+	}
+	if curveFlags.steps <= 0 {
+		chk(fmt.Errorf("bad number of steps: %d", curveFlags.steps))
+	}
+	r := rand.New(rand.NewSource(curveFlags.seed))
+	train, test, err := internal.SplitDocuments(args, curveFlags.test, r)
+	chk(err)
+	r.Shuffle(len(train), func(i, j int) {
+		train[i], train[j] = train[j], train[i]
+	})
+	var heldOut internal.Instances
+	for _, name := range test {
+		is := readInstances(name)
+		if heldOut.Len() > 0 && is.Cols() != heldOut.Cols() {
+			chk(fmt.Errorf("read instances %s: bad number of features", name))
+		}
+		heldOut.Append(is)
+	}
+	docs := make([]*internal.Instances, len(train))
+	for i, name := range train {
+		docs[i] = readInstances(name)
+		if docs[i].Cols() != heldOut.Cols() {
+			chk(fmt.Errorf("read instances %s: bad number of features", name))
+		}
+	}
+	x, y := heldOut.Mat()
+	lc := curve{Type: curveFlags.typ, Nocr: c.Nocr, Train: train, Test: test, HeldOut: heldOut.Len()}
+	var is internal.Instances
+	var n int
+	for step := 1; step <= curveFlags.steps; step++ {
+		// Add the documents of the next step (skip empty steps).
+		m := (step*len(docs) + curveFlags.steps - 1) / curveFlags.steps
+		if m == n {
+			continue
+		}
+		for ; n < m; n++ {
+			is.Append(docs[n])
+		}
+		conf := ml.Evaluate(is.Fit(tc), x, y, 0.5)
+		lc.Points = append(lc.Points, curvePoint{
+			Documents: n,
+			Tokens:    is.Len(),
+			Precision: conf.Precision(),
+			Recall:    conf.Recall(),
+			F1:        conf.F1(),
+			Accuracy:  conf.Accuracy(),
+		})
+	}
+	chk(lc.write(os.Stdout))
+}
+
+func readInstances(name string) *internal.Instances {
+	in, err := os.Open(name)
+	chk(err)
+	defer in.Close()
+	var is internal.Instances
+	chk(is.Read(in))
+	if is.Len() == 0 {
+		chk(fmt.Errorf("read instances %s: no instances", name))
+	}
+	return &is
+}
+
+// curve holds the data points of a learning curve.
+type curve struct {
+	Type        string
+	Nocr        int
+	Train, Test []string
+	HeldOut     int
+	Points      []curvePoint
+}
+
+type curvePoint struct {
+	Documents, Tokens               int
+	Precision, Recall, F1, Accuracy float64
+}
+
+func (lc *curve) write(out io.Writer) error {
+	if flags.json {
+		return json.NewEncoder(out).Encode(lc)
+	}
+	if curveFlags.format == "dat" {
+		f := formater{out: out}
+		f.printf("# %s/%d held-out=%d\n", lc.Type, lc.Nocr, lc.HeldOut)
+		f.printf("# tokens documents precision recall f1 accuracy\n")
+		for _, p := range lc.Points {
+			f.printf("%d %d %g %g %g %g\n", p.Tokens, p.Documents, p.Precision, p.Recall, p.F1, p.Accuracy)
+		}
+		return f.err
+	}
+	f := formater{out: out}
+	f.printf("%s/%d held-out documents %d\n", lc.Type, lc.Nocr, len(lc.Test))
+	f.printf("%s/%d held-out tokens %d\n", lc.Type, lc.Nocr, lc.HeldOut)
+	if f.err != nil {
+		return f.err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Tokens\tDocuments\tPrecision\tRecall\tF1\tAccuracy\n")
+	for _, p := range lc.Points {
+		fmt.Fprintf(w, "%d\t%d\t%f\t%f\t%f\t%f\n", p.Tokens, p.Documents, p.Precision, p.Recall, p.F1, p.Accuracy)
+	}
+	return w.Flush()
+}
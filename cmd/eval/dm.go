@@ -35,6 +35,9 @@ func dmRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
+	internal.UpdateInConfig(&c.OCRCands, flags.ocands)
+	internal.UpdateInConfig(&c.Hyphens, flags.hyphens)
 	internal.UpdateInConfig(&c.DM.Filter, dmFlags.filter)
 
 	m, err := internal.ReadModel(c.Model, c.LM, false)
@@ -42,12 +45,15 @@ func dmRun(_ *cobra.Command, args []string) {
 	lr, fs, err := m.Get("rr", c.Nocr)
 	chk(err)
 	p := internal.Piper{
-		Exts: flags.extensions,
-		Dirs: args,
+		Exts:     flags.extensions,
+		Dirs:     args,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
 	}
 	chk(p.Pipe(
 		context.Background(),
 		apoco.FilterBad(c.Nocr+1), // at least n ocr + ground truth
+		internal.JoinHyphenations(c),
 		apoco.Normalize(),
 		apoco.FilterShort(4),
 		apoco.ConnectLanguageModel(m.LM),
@@ -55,6 +61,7 @@ func dmRun(_ *cobra.Command, args []string) {
 		internal.ConnectProfile(c, "-profile.json.gz"),
 		apoco.FilterLexiconEntries(),
 		apoco.ConnectCandidates(),
+		internal.ConnectOCRCandidates(c),
 		apoco.ConnectRankings(lr, fs, c.Nocr),
 		dmEval(c, m),
 	))
@@ -70,17 +77,19 @@ func dmEval(c *internal.Config, m *internal.Model) apoco.StreamFunc {
 			return fail(err)
 		}
 		var xs, ys []float64
+		var ts []apoco.T
 		err = apoco.EachToken(ctx, in, func(t apoco.T) error {
 			xs = fs.Calculate(xs, t, c.Nocr)
 			ys = append(ys, dmGT(t))
+			ts = append(ts, t)
 			return nil
 		})
 		if err != nil {
 			return fail(err)
 		}
-		var s stats
-		s.eval(lr, 0.5, xs, ys)
-		return s.print(os.Stdout, "dm", c.Nocr)
+		var e evaluation
+		e.eval(lr, 0.5, xs, ys, ts)
+		return e.print(os.Stdout, "dm", c.Nocr)
 	}
 }
 
@@ -0,0 +1,215 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"unicode/utf8"
+
+	"git.sr.ht/~flobar/apoco/cmd/correct"
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"git.sr.ht/~flobar/lev"
+	"github.com/spf13/cobra"
+)
+
+// e2eCmd defines the apoco eval e2e command.
+var e2eCmd = &cobra.Command{
+	Use:   "e2e [DIRS...]",
+	Short: "Evaluate the whole correction pipeline",
+	Long: `
+Runs the correction pipeline of apoco correct on the ground-truth
+data in DIRS and reports the word accuracy, the char error rate, the
+precision and recall of the corrections and the number of tokens of
+each stok type.  Each directory is treated as one document.
+
+If a test fraction is given, the documents are randomly split into a
+training and a test set.  New re-ranking and decision maker models
+are trained on the training documents (the models are not saved) and
+the correction is evaluated on the test documents only.`,
+	Run: e2eRun,
+}
+
+var e2eFlags = struct {
+	filter string
+	test   float64
+	seed   int64
+}{}
+
+func init() {
+	e2eCmd.Flags().StringVarP(&e2eFlags.filter, "filter", "f", "",
+		"set the filter for the dm training (overwrites the setting in the configuration file)")
+	e2eCmd.Flags().Float64VarP(&e2eFlags.test, "test", "T", 0,
+		"set the fraction of test documents and train new models on the remaining documents")
+	e2eCmd.Flags().Int64VarP(&e2eFlags.seed, "seed", "s", 1,
+		"set the seed for the split of the documents")
+}
+
+func e2eRun(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(flags.parameter)
+	chk(err)
+
+	internal.UpdateInConfig(&c.Model, flags.model)
+	internal.UpdateInConfig(&c.Nocr, flags.nocr)
+	internal.UpdateInConfig(&c.Cache, flags.cache)
+	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
+	internal.UpdateInConfig(&c.OCRCands, flags.ocands)
+	internal.UpdateInConfig(&c.Hyphens, flags.hyphens)
+	internal.UpdateInConfig(&c.DM.Filter, e2eFlags.filter)
+
+	train, test := []string(nil), args
+	if e2eFlags.test > 0 {
+		train, test, err = internal.SplitDocuments(args, e2eFlags.test,
+			rand.New(rand.NewSource(e2eFlags.seed)))
+		chk(err)
+	}
+	m, err := internal.ReadModel(c.Model, c.LM, len(train) > 0)
+	chk(err)
+	if len(train) > 0 {
+		chk(e2eTrain(c, m, train))
+	}
+	p := internal.Piper{
+		Exts:     flags.extensions,
+		Dirs:     test,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+	}
+	stoks, err := correct.Stoks(context.Background(), p, c, m, true)
+	chk(err)
+	s := e2eStats{Train: train, Test: test, Types: make(map[string]int)}
+	for _, stok := range stoks {
+		s.add(stok)
+	}
+	chk(s.print(os.Stdout, c.Nocr))
+}
+
+// e2eTrain trains new re-ranking and decision maker models on the
+// given documents and puts them into the model.
+func e2eTrain(c *internal.Config, m *internal.Model, docs []string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("eval e2e/%d: train: %v", c.Nocr, err)
+	}
+	p := internal.Piper{
+		Exts:     flags.extensions,
+		Dirs:     docs,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+	}
+	apoco.Log("e2e: training on %d documents", len(docs))
+	rrfs, err := apoco.NewFeatureSet(c.RR.Features...)
+	if err != nil {
+		return fail(err)
+	}
+	var rr internal.Instances
+	err = p.Pipe(context.Background(),
+		append(internal.CandidateStreams(c, m), rr.Collect(rrfs, c.Nocr, internal.RRGT))...)
+	if err != nil {
+		return fail(err)
+	}
+	if rr.Len() == 0 {
+		return fail(fmt.Errorf("no rr instances"))
+	}
+	m.Put("rr", c.Nocr, rr.Fit(c.RR), c.RR.Features)
+	lr, fs, err := m.Get("rr", c.Nocr)
+	if err != nil {
+		return fail(err)
+	}
+	dmfs, err := apoco.NewFeatureSet(c.DM.Features...)
+	if err != nil {
+		return fail(err)
+	}
+	var dm internal.Instances
+	err = p.Pipe(context.Background(),
+		append(internal.CandidateStreams(c, m),
+			apoco.ConnectRankings(lr, fs, c.Nocr),
+			dm.Collect(dmfs, c.Nocr, internal.DMGT(c.DM.Filter)))...)
+	if err != nil {
+		return fail(err)
+	}
+	if dm.Len() == 0 {
+		return fail(fmt.Errorf("no dm instances"))
+	}
+	m.Put("dm", c.Nocr, dm.Fit(c.DM.TrainingConfig), c.DM.Features)
+	return nil
+}
+
+// e2eStats holds the stats of the end to end evaluation.
+type e2eStats struct {
+	Train, Test                              []string
+	Tokens, ErrorsBefore, ErrorsAfter        int
+	Chars, CharErrorsBefore, CharErrorsAfter int
+	Corrections, SuccessfulCorrections       int
+	AccuracyBefore, AccuracyAfter            float64
+	CharErrorRateBefore, CharErrorRateAfter  float64
+	Precision, Recall, F1                    float64
+	Types                                    map[string]int
+	mat                                      lev.Mat
+}
+
+func (s *e2eStats) add(stok internal.Stok) {
+	typ := stok.Type()
+	s.Types[typ.String()]++
+	s.Tokens++
+	if stok.ErrBefore() {
+		s.ErrorsBefore++
+	}
+	if stok.ErrAfter() {
+		s.ErrorsAfter++
+	}
+	before := s.mat.Distance(stok.OCR, stok.GT)
+	after := before
+	if stok.Cor {
+		after = s.mat.Distance(stok.Sug, stok.GT)
+	}
+	s.Chars += utf8.RuneCountInString(stok.GT)
+	s.CharErrorsBefore += before
+	s.CharErrorsAfter += after
+	// Redundant corrections do not change the token and are not
+	// counted as corrections.
+	if !stok.Skipped && stok.Cor && typ != internal.RedundantCorrection {
+		s.Corrections++
+		if stok.ErrBefore() && !stok.ErrAfter() {
+			s.SuccessfulCorrections++
+		}
+	}
+}
+
+// finish calculates the rates of the stats.
+func (s *e2eStats) finish() {
+	s.AccuracyBefore = 1 - ml.Ratio(s.ErrorsBefore, s.Tokens)
+	s.AccuracyAfter = 1 - ml.Ratio(s.ErrorsAfter, s.Tokens)
+	s.CharErrorRateBefore = ml.Ratio(s.CharErrorsBefore, s.Chars)
+	s.CharErrorRateAfter = ml.Ratio(s.CharErrorsAfter, s.Chars)
+	s.Precision = ml.Ratio(s.SuccessfulCorrections, s.Corrections)
+	s.Recall = ml.Ratio(s.SuccessfulCorrections, s.ErrorsBefore)
+	if s.Precision+s.Recall > 0 {
+		s.F1 = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+	}
+}
+
+func (s *e2eStats) print(out io.Writer, nocr int) error {
+	s.finish()
+	if flags.json {
+		return json.NewEncoder(out).Encode(s)
+	}
+	f := formater{out: out}
+	f.printf("e2e/%d documents %d/%d\n", nocr, len(s.Train), len(s.Test))
+	f.printf("e2e/%d tokens %d\n", nocr, s.Tokens)
+	f.printf("e2e/%d acc %f/%f\n", nocr, s.AccuracyBefore, s.AccuracyAfter)
+	f.printf("e2e/%d cer %f/%f\n", nocr, s.CharErrorRateBefore, s.CharErrorRateAfter)
+	f.printf("e2e/%d corrections %d/%d\n", nocr, s.SuccessfulCorrections, s.Corrections)
+	f.printf("e2e/%d pr %f\n", nocr, s.Precision)
+	f.printf("e2e/%d re %f\n", nocr, s.Recall)
+	f.printf("e2e/%d f1 %f\n", nocr, s.F1)
+	for typ := internal.SkippedShort; typ <= internal.SuspiciousNotReplacedNotCorrectErr; typ++ {
+		if n := s.Types[typ.String()]; n > 0 {
+			f.printf("e2e/%d %s %d\n", nocr, typ, n)
+		}
+	}
+	return f.err
+}
@@ -7,7 +7,6 @@ import (
 
 	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
 	"github.com/spf13/cobra"
-	"gonum.org/v1/gonum/mat"
 )
 
 // Cmd defines the apoco eval command.
@@ -17,10 +16,12 @@ var Cmd = &cobra.Command{
 }
 
 var flags = struct {
-	extensions                    []string
-	parameter, model              string
-	nocr                          int
-	cache, cautious, update, alev bool
+	extensions                                           []string
+	parameter, model                                     string
+	nocr                                                 int
+	outliers                                             float64
+	cache, cautious, update, alev, amsa, ocands, hyphens bool
+	json, breakdown                                      bool
 }{}
 
 func init() {
@@ -37,12 +38,19 @@ func init() {
 		"enable caching of profiles (overwrites the setting in the configuration file)")
 	Cmd.PersistentFlags().BoolVarP(&flags.alev, "alignlev", "v", false,
 		"align using Levenshtein (matrix) alignment")
+	Cmd.PersistentFlags().BoolVarP(&flags.amsa, "alignmsa", "S", false,
+		"align using multiple sequence alignment (overwrites --alignlev)")
+	Cmd.PersistentFlags().BoolVarP(&flags.ocands, "ocrcands", "u", false,
+		"use the support OCR tokens as additional candidates")
+	Cmd.PersistentFlags().BoolVarP(&flags.hyphens, "hyphens", "H", false,
+		"join hyphenated tokens at the end of lines")
+	Cmd.PersistentFlags().BoolVarP(&flags.breakdown, "breakdown", "b", false,
+		"print the stats of each document and page")
+	Cmd.PersistentFlags().Float64VarP(&flags.outliers, "outliers", "O", 0,
+		"flag documents whose f1 z-score is at least the given threshold (0 disables)")
+	Cmd.PersistentFlags().BoolVarP(&flags.json, "json", "J", false, "set json output")
 	// Subcommands
-	Cmd.AddCommand(rrCmd, dmCmd, msCmd, ffCmd)
-}
-
-type stats struct {
-	tn, tp, fn, fp int
+	Cmd.AddCommand(rrCmd, dmCmd, msCmd, ffCmd, e2eCmd, curveCmd)
 }
 
 type typ int
@@ -54,69 +62,29 @@ const (
 	fn
 )
 
-func (s *stats) eval(p ml.Predictor, t float64, xs, ys []float64) {
-	xlen := len(xs)
-	ylen := len(ys)
-	x := mat.NewDense(ylen, xlen/ylen, xs)
-	y := mat.NewVecDense(ylen, ys)
-	ps := p.Predict(x)
-	ml.ApplyThreshold(ps, t)
-	for i := 0; i < ylen; i++ {
-		s.add(y.AtVec(i), ps.AtVec(i))
-	}
-}
-
-func (s *stats) add(y, p float64) typ {
-	if y == ml.True {
-		if y == p {
-			s.tp++
-			return tp
-		} else {
-			s.fn++
-			return fn
-		}
-	} else {
-		if y == p {
-			s.tn++
-			return tn
-		} else {
-			s.fp++
-			return fp
-		}
-	}
-}
-
-func (s *stats) recall() float64 {
-	if s.tp == 0 && s.fn == 0 {
-		return 0
-	}
-	return float64(s.tp) / float64(s.tp+s.fn)
-}
-
-func (s *stats) precision() float64 {
-	if s.tp == 0 && s.fp == 0 {
-		return 0
-	}
-	return float64(s.tp) / float64(s.tp+s.fp)
-}
-
-func (s *stats) f1() float64 {
-	p, r := s.precision(), s.recall()
-	if p == 0 && r == 0 {
-		return 0
+// classify returns the type of the prediction p for the gold value y.
+func classify(y, p float64) typ {
+	switch {
+	case y == ml.True && p == ml.True:
+		return tp
+	case y == ml.True:
+		return fn
+	case p == ml.True:
+		return fp
+	default:
+		return tn
 	}
-	return (2 * p * r) / (p + r)
 }
 
-func (s *stats) print(out io.Writer, typ string, nocr int) error {
+func printConfusion(out io.Writer, c ml.Confusion, typ string, nocr int) error {
 	f := formater{out: out}
-	f.printf("%s/%d tp %d\n", typ, nocr, s.tp)
-	f.printf("%s/%d fp %d\n", typ, nocr, s.fp)
-	f.printf("%s/%d tn %d\n", typ, nocr, s.tn)
-	f.printf("%s/%d fn %d\n", typ, nocr, s.fn)
-	f.printf("%s/%d pr %f\n", typ, nocr, s.precision())
-	f.printf("%s/%d re %f\n", typ, nocr, s.recall())
-	f.printf("%s/%d f1 %f\n", typ, nocr, s.f1())
+	f.printf("%s/%d tp %d\n", typ, nocr, c.TP)
+	f.printf("%s/%d fp %d\n", typ, nocr, c.FP)
+	f.printf("%s/%d tn %d\n", typ, nocr, c.TN)
+	f.printf("%s/%d fn %d\n", typ, nocr, c.FN)
+	f.printf("%s/%d pr %f\n", typ, nocr, c.Precision())
+	f.printf("%s/%d re %f\n", typ, nocr, c.Recall())
+	f.printf("%s/%d f1 %f\n", typ, nocr, c.F1())
 	return f.err
 }
 
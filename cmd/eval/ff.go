@@ -28,6 +28,7 @@ func ffRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
 
 	profile_path := strings.Replace(args[0], "corpus", "profiles-c", -1)
 	//	profile_path :=  strings.Replace(args[0],"corpus","profiles-b",-1)
@@ -48,6 +49,7 @@ func ffRun(_ *cobra.Command, args []string) {
 		Exts:     flags.extensions,
 		Dirs:     args,
 		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
 	}
 	chk(p.Pipe(
 		context.Background(),
@@ -80,7 +82,7 @@ func ffEval(c *internal.Config, m *internal.Model) apoco.StreamFunc {
 		if err != nil {
 			return fmt.Errorf("ffeval: %v", err)
 		}
-		var s stats
+		var e evaluation
 		ps := lr.Predict(mat.NewDense(len(ys), len(xs)/len(ys), xs))
 		// s.eval(lr, 0.5, xs, ys)
 		// s.print(os.Stdout, "dm", c.Nocr)
@@ -89,7 +91,7 @@ func ffEval(c *internal.Config, m *internal.Model) apoco.StreamFunc {
 			//cs := ts[i].Document.Profile[ts[i].Tokens[0]]
 			//fmt.Printf("Candidates: %d\n",len(cs.Candidates))
 
-			switch s.add(ys[i], ps.AtVec(i)) {
+			switch e.add(ts[i], ys[i], ps.AtVec(i)) {
 			case tp:
 				//fmt.Printf("True Positive: " + ts[i].Tokens[0]+ "  :  "+ts[i].GT()+"\n")
 			case fp:
@@ -97,7 +99,7 @@ func ffEval(c *internal.Config, m *internal.Model) apoco.StreamFunc {
 			}
 
 		}
-		return s.print(os.Stdout, "ff", c.Nocr)
+		return e.print(os.Stdout, "ff", c.Nocr)
 	}
 }
 
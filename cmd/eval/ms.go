@@ -36,12 +36,15 @@ func msRun(_ *cobra.Command, args []string) {
 	internal.UpdateInConfig(&c.Nocr, flags.nocr)
 	internal.UpdateInConfig(&c.Cache, flags.cache)
 	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
 
 	m, err := internal.ReadModel(c.Model, c.LM, false)
 	chk(err)
 	p := internal.Piper{
-		Exts: flags.extensions,
-		Dirs: args,
+		Exts:     flags.extensions,
+		Dirs:     args,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
 	}
 	chk(p.Pipe(
 		context.Background(),
@@ -68,7 +71,7 @@ func msEval(c *internal.Config, m *internal.Model, threshold float64, update boo
 		}
 		var xs []float64
 		var x *mat.Dense
-		var s stats
+		var e evaluation
 		names := fs.Names(c.MS.Features, "ms", c.Nocr)
 		err = apoco.EachToken(ctx, in, func(t apoco.T) error {
 			gt := msGT(t)
@@ -79,7 +82,7 @@ func msEval(c *internal.Config, m *internal.Model, threshold float64, update boo
 			}
 			// pred := lr.Predict(x, threshold)
 			probs := lr.Predict(x)
-			switch s.add(gt, ml.Bool(probs.AtVec(0) >= threshold)) { //} pred.AtVec(0)) {
+			switch e.add(t, gt, ml.Bool(probs.AtVec(0) >= threshold)) { //} pred.AtVec(0)) {
 			case tp:
 				apoco.Log("true positive: %s (%g) %s", tstr(t), probs.AtVec(0), fs2str(xs, names))
 				/*case fp:
@@ -93,7 +96,7 @@ func msEval(c *internal.Config, m *internal.Model, threshold float64, update boo
 		if err != nil {
 			return err
 		}
-		return s.print(os.Stdout, "ms", c.Nocr)
+		return e.print(os.Stdout, "ms", c.Nocr)
 	}
 }
 
@@ -23,6 +23,9 @@ type Config struct {
 	Cache    bool                      `json:"cache"`
 	GT       bool                      `json:"gt"`
 	AlignLev bool                      `json:"alignLev"`
+	AlignMSA bool                      `json:"alignMSA"`
+	OCRCands bool                      `json:"ocrCands"`
+	Hyphens  bool                      `json:"hyphens"`
 	Lex      bool                      `json:"lex"`
 }
 
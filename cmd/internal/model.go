@@ -12,11 +12,11 @@ type (
 	ModelData = apoco.ModelData
 )
 
-// ReadModel reads a model from a gob compressed input file.  If the
-// given file does not exist, the according language models are loaded
-// and a new model is returned.  If create is set to false no new
-// model will be created and the model must be read from an existing
-// file.
+// ReadModel reads a model from a gob encoded or a gzipped json input
+// file.  If the given file does not exist, the according language
+// models are loaded and a new model is returned.  If create is set to
+// false no new model will be created and the model must be read from
+// an existing file.
 func ReadModel(name string, lms map[string]apoco.LMConfig, create bool) (*Model, error) {
 	return apoco.ReadModel(name, lms, create)
 }
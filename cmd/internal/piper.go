@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/align"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/pagexml"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/snippets"
 )
@@ -12,24 +13,45 @@ type Piper struct {
 	IFGS, Exts, Dirs []string
 	METS             string
 	AlignLev         bool
+	AlignMSA         bool    // Use multiple sequence alignment (overrides AlignLev).
+	Lines            bool    // Tokenize page xml on the line level.
+	Pages            string  // Page id selection for METS file groups (see mets.PageIDs).
+	MinAlign         float64 // Filter lines with a lower alignment agreement (see apoco.FilterBadAlignments).
+	Nocr             int     // Number of OCRs for the alignment agreement (see MinAlign).
 }
 
 func (p Piper) Pipe(ctx context.Context, fns ...apoco.StreamFunc) error {
+	if p.MinAlign > 0 {
+		fns = append([]apoco.StreamFunc{apoco.FilterBadAlignments(p.MinAlign, p.Nocr)}, fns...)
+	}
 	if len(p.IFGS) > 0 {
-		return apoco.Pipe(
-			ctx,
-			append([]apoco.StreamFunc{pagexml.Tokenize(p.METS, p.IFGS...)}, fns...)...,
-		)
+		tokenize := pagexml.Tokenize(p.METS, p.Pages, p.IFGS...)
+		if p.Lines {
+			tokenize = pagexml.TokenizeLines(p.METS, p.Pages, p.alignMode(), p.IFGS...)
+		}
+		return apoco.Pipe(ctx, append([]apoco.StreamFunc{tokenize}, fns...)...)
 	}
 	if len(p.Exts) == 1 && p.Exts[0] == ".xml" {
-		return apoco.Pipe(
-			ctx,
-			append([]apoco.StreamFunc{pagexml.TokenizeDirs(p.Exts[0], p.Dirs...)}, fns...)...,
-		)
+		tokenize := pagexml.TokenizeDirs(p.Exts[0], p.Dirs...)
+		if p.Lines {
+			tokenize = pagexml.TokenizeLinesDirs(p.Exts[0], p.alignMode(), p.Dirs...)
+		}
+		return apoco.Pipe(ctx, append([]apoco.StreamFunc{tokenize}, fns...)...)
 	}
 	e := snippets.Extensions(p.Exts)
 	return apoco.Pipe(
 		ctx,
-		append([]apoco.StreamFunc{e.ReadLines(p.Dirs...), e.TokenizeLines(p.AlignLev)}, fns...)...,
+		append([]apoco.StreamFunc{e.ReadLines(p.Dirs...), e.TokenizeLines(p.alignMode())}, fns...)...,
 	)
 }
+
+func (p Piper) alignMode() align.Mode {
+	switch {
+	case p.AlignMSA:
+		return align.ModeMSA
+	case p.AlignLev:
+		return align.ModeLev
+	default:
+		return align.ModeDo
+	}
+}
@@ -16,6 +16,7 @@ import (
 type Stok struct {
 	OCR, Sug, GT, ID         string
 	OCRConfs                 []float64
+	Cands                    []string // Optional candidates (see apoco correct --cands).
 	Conf                     float64
 	Rank                     int
 	Skipped, Short, Lex, Cor bool
@@ -90,6 +91,10 @@ func MakeStokFromLine(line string) (Stok, error) {
 			if _, err := fmt.Sscanf(tok, "gt=%s", &stok.GT); err != nil {
 				return stok, fmt.Errorf("bad stats line %s: %v", line, err)
 			}
+		case strings.HasPrefix(tok, "cands="):
+			if cands := tok[len("cands="):]; cands != Epsilon {
+				stok.Cands = strings.Split(cands, "/")
+			}
 		case strings.HasPrefix(tok, "type="):
 			// The command print types adds an additional type=... argument to
 			// the stats IO.  Ignore this argument for reading stoks.
@@ -101,9 +106,13 @@ func MakeStokFromLine(line string) (Stok, error) {
 }
 
 func (s Stok) String() string {
-	return fmt.Sprintf("id=%s skipped=%t short=%t lex=%t cor=%t ocrconfs=%s conf=%g rank=%d ocr=%s sug=%s gt=%s",
+	str := fmt.Sprintf("id=%s skipped=%t short=%t lex=%t cor=%t ocrconfs=%s conf=%g rank=%d ocr=%s sug=%s gt=%s",
 		s.ID, s.Skipped, s.Short, s.Lex, s.Cor, ocrconfs(s.OCRConfs),
 		s.Conf, s.Rank, E(s.OCR), E(s.Sug), E(s.GT))
+	if len(s.Cands) > 0 {
+		str += " cands=" + strings.Join(s.Cands, "/")
+	}
+	return str
 
 }
 
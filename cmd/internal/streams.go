@@ -1,6 +1,10 @@
 package internal
 
-import "git.sr.ht/~flobar/apoco/pkg/apoco"
+import (
+	"context"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+)
 
 func FilterLex(c *Config) apoco.StreamFunc {
 	if c.Lex {
@@ -8,3 +12,31 @@ func FilterLex(c *Config) apoco.StreamFunc {
 	}
 	return apoco.FilterLexiconEntries()
 }
+
+// ConnectOCRCandidates adds the support OCR tokens as additional
+// candidates if the according setting is enabled.  Otherwise the
+// tokens are passed through unchanged.
+func ConnectOCRCandidates(c *Config) apoco.StreamFunc {
+	if c.OCRCands {
+		return apoco.ConnectOCRCandidates(c.Nocr)
+	}
+	return pass()
+}
+
+// JoinHyphenations joins hyphenated tokens at the end of lines if the
+// according setting is enabled.  Otherwise the tokens are passed
+// through unchanged.
+func JoinHyphenations(c *Config) apoco.StreamFunc {
+	if c.Hyphens {
+		return apoco.JoinHyphenations()
+	}
+	return pass()
+}
+
+func pass() apoco.StreamFunc {
+	return func(ctx context.Context, in <-chan apoco.T, out chan<- apoco.T) error {
+		return apoco.EachToken(ctx, in, func(t apoco.T) error {
+			return apoco.SendTokens(ctx, out, t)
+		})
+	}
+}
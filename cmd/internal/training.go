@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/finkf/gofiler"
+	"gonum.org/v1/gonum/mat"
+)
+
+// RRGT returns the ground-truth value for the re-ranking of the
+// token's candidate.  All tokens are used for the training.
+func RRGT(t apoco.T) (float64, bool) {
+	candidate := t.Payload.(*gofiler.Candidate)
+	return ml.Bool(candidate.Suggestion == t.Tokens[len(t.Tokens)-1]), true
+}
+
+// DMGT returns a function that returns the ground-truth value for
+// the decision of the token's top ranked candidate and if the token
+// should be used for the training with the given filter (see
+// UseTokenForDMTraining).
+func DMGT(filter string) func(apoco.T) (float64, bool) {
+	return func(t apoco.T) (float64, bool) {
+		use := UseTokenForDMTraining(t, filter)
+		sug := t.Payload.([]apoco.Ranking)[0].Candidate.Suggestion
+		gt := t.Tokens[len(t.Tokens)-1]
+		return ml.Bool(sug == gt), use
+	}
+}
+
+// UseTokenForDMTraining returns true if the token should be used for
+// the training of the decision maker with the given filter.
+func UseTokenForDMTraining(t apoco.T, filter string) bool {
+	if filter == Cautious {
+		return true
+	}
+	ocr := t.Tokens[0]
+	gt := t.Tokens[len(t.Tokens)-1]
+	// If ocr != gt we use the token if the correction suggestion is correct.
+	// We skip token with "don't care corrections" (incorrect correction
+	// for an incorrect ocr token).
+	if ocr != gt {
+		return t.Payload.([]apoco.Ranking)[0].Candidate.Suggestion == gt
+	}
+	// We do not want to train with redundant corrections (ocr == gt && sugg == gt).
+	// If ocr == gt and sugg == gt we skip the token for the training.
+	// Note that at this point ocr == gt holds (see above).
+	if filter == Redundant {
+		return t.Payload.([]apoco.Ranking)[0].Candidate.Suggestion != gt
+	}
+	return true
+}
+
+// Instances holds the feature vectors and the according ground-truth
+// values of training or evaluation instances.
+type Instances struct {
+	Xs, Ys []float64
+}
+
+// Collect returns a stream function that appends the features of the
+// tokens as new instances.  The gt function returns the ground-truth
+// value of a token and if the token should be used at all.
+func (is *Instances) Collect(fs apoco.FeatureSet, nocr int, gt func(apoco.T) (float64, bool)) apoco.StreamFunc {
+	return func(ctx context.Context, in <-chan apoco.T, _ chan<- apoco.T) error {
+		return apoco.EachToken(ctx, in, func(t apoco.T) error {
+			y, use := gt(t)
+			if !use {
+				return nil
+			}
+			is.Xs = fs.Calculate(is.Xs, t, nocr)
+			is.Ys = append(is.Ys, y)
+			return nil
+		})
+	}
+}
+
+// Len returns the number of instances.
+func (is *Instances) Len() int {
+	return len(is.Ys)
+}
+
+// Cols returns the number of features of each instance.
+func (is *Instances) Cols() int {
+	if len(is.Ys) == 0 {
+		return 0
+	}
+	return len(is.Xs) / len(is.Ys)
+}
+
+// Append appends the instances of other.  Both instances must have
+// the same number of features.
+func (is *Instances) Append(other *Instances) {
+	is.Xs = append(is.Xs, other.Xs...)
+	is.Ys = append(is.Ys, other.Ys...)
+}
+
+// Columns returns a copy of the instances that only contains the
+// given feature columns.
+func (is *Instances) Columns(cols []int) *Instances {
+	n := is.Cols()
+	ret := &Instances{
+		Xs: make([]float64, 0, len(cols)*len(is.Ys)),
+		Ys: is.Ys,
+	}
+	for i := range is.Ys {
+		for _, col := range cols {
+			ret.Xs = append(ret.Xs, is.Xs[i*n+col])
+		}
+	}
+	return ret
+}
+
+// Read reads instances from a training CSV file (see apoco csv).  The
+// last value of each line is the ground-truth value of the instance.
+func (is *Instances) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		vals := strings.Split(s.Text(), ",")
+		for i := range vals {
+			val, err := strconv.ParseFloat(vals[i], 64)
+			if err != nil {
+				return fmt.Errorf("read instances: %v", err)
+			}
+			if i == len(vals)-1 {
+				is.Ys = append(is.Ys, val)
+			} else {
+				is.Xs = append(is.Xs, val)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("read instances: %v", err)
+	}
+	return nil
+}
+
+// Mat returns the feature matrix and the ground-truth vector of the
+// instances.  There must be at least one instance.
+func (is *Instances) Mat() (*mat.Dense, *mat.VecDense) {
+	n := len(is.Ys)
+	return mat.NewDense(n, len(is.Xs)/n, is.Xs), mat.NewVecDense(n, is.Ys)
+}
+
+// Fit fits a new linear regression model on the instances using the
+// given training settings.  There must be at least one instance.
+func (is *Instances) Fit(c TrainingConfig) *ml.LR {
+	lr := &ml.LR{LearningRate: c.LearningRate, Ntrain: c.Ntrain}
+	x, y := is.Mat()
+	lr.Fit(x, y)
+	return lr
+}
+
+// CandidateStreams returns the stream functions of the training
+// pipeline that connect the candidates of the tokens (see the csv rr
+// and csv dm commands).  The tokens must contain the ground-truth.
+func CandidateStreams(c *Config, m *Model) []apoco.StreamFunc {
+	return []apoco.StreamFunc{
+		apoco.FilterBad(c.Nocr + 1), // at least n ocr + ground truth
+		JoinHyphenations(c),
+		apoco.Normalize(),
+		apoco.FilterShort(4),
+		apoco.ConnectLanguageModel(m.LM),
+		apoco.ConnectUnigrams(),
+		ConnectProfile(c, "-profile.json.gz"),
+		FilterLex(c),
+		apoco.ConnectCandidates(),
+		ConnectOCRCandidates(c),
+	}
+}
+
+// SplitDocuments randomly splits the given documents into a training
+// and a test set.  The given fraction of the documents (at least one)
+// is used for the test set.  The remaining documents (at least one)
+// are used for the training.  Both sets keep the original order of
+// the documents.
+func SplitDocuments(docs []string, frac float64, r *rand.Rand) ([]string, []string, error) {
+	if len(docs) < 2 {
+		return nil, nil, fmt.Errorf("split documents: need at least two documents")
+	}
+	n := int(math.Round(frac * float64(len(docs))))
+	if n < 1 {
+		n = 1
+	}
+	if n >= len(docs) {
+		n = len(docs) - 1
+	}
+	test := make(map[int]bool, n)
+	for _, i := range r.Perm(len(docs))[:n] {
+		test[i] = true
+	}
+	var train, tests []string
+	for i, doc := range docs {
+		if test[i] {
+			tests = append(tests, doc)
+		} else {
+			train = append(train, doc)
+		}
+	}
+	return train, tests, nil
+}
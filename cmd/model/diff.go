@@ -0,0 +1,144 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd runs the apoco model diff command.
+var diffCmd = &cobra.Command{
+	Use:   "diff A B",
+	Short: "Compare the features and weights of two models",
+	Args:  cobra.ExactArgs(2),
+	Run:   runDiff,
+	Long: `
+Compares the feature lists and weights of the models A and B
+for each model type and number of OCRs.  Each output line is
+marked with a prefix:
+  '-' the feature (or model) is only in A,
+  '+' the feature (or model) is only in B,
+  '~' the feature's weight differs,
+  ' ' the feature's weight is the same (see --all).`,
+}
+
+var diffFlags = struct {
+	eps float64
+	all bool
+}{}
+
+func init() {
+	diffCmd.Flags().Float64VarP(&diffFlags.eps, "epsilon", "e", 0,
+		"set the maximal absolute difference for equal weights")
+	diffCmd.Flags().BoolVarP(&diffFlags.all, "all", "a", false,
+		"output unchanged features as well")
+}
+
+func runDiff(_ *cobra.Command, args []string) {
+	a, err := internal.ReadModel(args[0], nil, false)
+	chk(err)
+	b, err := internal.ReadModel(args[1], nil, false)
+	chk(err)
+	ds, err := diffModels(a, b, diffFlags.eps)
+	chk(err)
+	if flags.json {
+		chk(json.NewEncoder(os.Stdout).Encode(ds))
+		return
+	}
+	chk(printDiffs(os.Stdout, args[0], args[1], ds, diffFlags.all))
+}
+
+// Markers for the different kinds of changes.
+const (
+	same    = " "
+	removed = "-"
+	added   = "+"
+	changed = "~"
+)
+
+type diff struct {
+	Model   string
+	Feature string  `json:",omitempty"`
+	Mark    string  // One of " ", "-", "+" or "~".
+	A, B    float64 // Weights of the feature in A and B.
+}
+
+// diffModels compares the models a and b.  Model data that is
+// present in only one of the two models is reported with an empty
+// feature name.
+func diffModels(a, b *internal.Model, eps float64) ([]diff, error) {
+	var ds []diff
+	for _, k := range sortedKeys(a, b) {
+		adata, aok := lookup(a, k)
+		bdata, bok := lookup(b, k)
+		switch {
+		case !aok:
+			ds = append(ds, diff{Model: k.String(), Mark: added})
+			continue
+		case !bok:
+			ds = append(ds, diff{Model: k.String(), Mark: removed})
+			continue
+		}
+		anames, aws, err := weights(k, adata)
+		if err != nil {
+			return nil, err
+		}
+		bnames, bws, err := weights(k, bdata)
+		if err != nil {
+			return nil, err
+		}
+		bmap := make(map[string]float64, len(bnames))
+		for i := range bnames {
+			bmap[bnames[i]] = bws[i]
+		}
+		amap := make(map[string]bool, len(anames))
+		for i, name := range anames {
+			amap[name] = true
+			d := diff{Model: k.String(), Feature: name, A: aws[i]}
+			w, ok := bmap[name]
+			switch {
+			case !ok:
+				d.Mark = removed
+			case math.Abs(aws[i]-w) > eps:
+				d.Mark, d.B = changed, w
+			default:
+				d.Mark, d.B = same, w
+			}
+			ds = append(ds, d)
+		}
+		for i, name := range bnames {
+			if !amap[name] {
+				ds = append(ds, diff{Model: k.String(), Feature: name, Mark: added, B: bws[i]})
+			}
+		}
+	}
+	return ds, nil
+}
+
+func printDiffs(out io.Writer, a, b string, ds []diff, all bool) error {
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	f := formater{out: w}
+	f.printf("--- %s\n", a)
+	f.printf("+++ %s\n", b)
+	for _, d := range ds {
+		switch {
+		case d.Feature == "":
+			f.printf("%s\t%s\n", d.Mark, d.Model)
+		case d.Mark == removed:
+			f.printf("%s\t%s\t%s\t%g\n", d.Mark, d.Model, d.Feature, d.A)
+		case d.Mark == added:
+			f.printf("%s\t%s\t%s\t\t%g\n", d.Mark, d.Model, d.Feature, d.B)
+		case d.Mark == changed || all:
+			f.printf("%s\t%s\t%s\t%g\t%g\t%+g\n", d.Mark, d.Model, d.Feature, d.A, d.B, d.B-d.A)
+		}
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd runs the apoco model export command.
+var exportCmd = &cobra.Command{
+	Use:   "export IN OUT",
+	Short: "Export a model to the portable json format",
+	Args:  cobra.ExactArgs(2),
+	Run:   runExport,
+	Long: `
+Exports the model IN to the gzipped json model format
+and writes it to OUT.`,
+}
+
+// importCmd runs the apoco model import command.
+var importCmd = &cobra.Command{
+	Use:   "import IN OUT",
+	Short: "Import a model from the portable json format",
+	Args:  cobra.ExactArgs(2),
+	Run:   runImport,
+	Long: `
+Imports the (gzipped) json model IN and writes it
+gob encoded to OUT.`,
+}
+
+func runExport(_ *cobra.Command, args []string) {
+	m, err := internal.ReadModel(args[0], nil, false)
+	chk(err)
+	chk(m.WriteJSON(args[1]))
+}
+
+func runImport(_ *cobra.Command, args []string) {
+	m, err := internal.ReadModel(args[0], nil, false)
+	chk(err)
+	chk(m.WriteGob(args[1]))
+}
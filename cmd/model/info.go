@@ -0,0 +1,130 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"github.com/spf13/cobra"
+)
+
+// infoCmd runs the apoco model info command.
+var infoCmd = &cobra.Command{
+	Use:   "info [MODEL...]",
+	Short: "Print training metadata of models",
+	Run:   runInfo,
+}
+
+var infoFlags = struct {
+	config bool
+}{}
+
+func init() {
+	infoCmd.Flags().BoolVarP(&infoFlags.config, "config", "c", false,
+		"output the training configuration")
+}
+
+func runInfo(_ *cobra.Command, args []string) {
+	for _, name := range args {
+		m, err := internal.ReadModel(name, nil, false)
+		chk(err)
+		if flags.json {
+			chk(infojson(os.Stdout, name, m))
+		} else {
+			chk(info(os.Stdout, name, m))
+		}
+	}
+}
+
+func info(out io.Writer, name string, m *internal.Model) error {
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	f := formater{out: w}
+	f.printf("%s\t\tlanguage models\t%d\n", name, len(m.LM))
+	for _, k := range sortedKeys(m) {
+		data, _ := lookup(m, k)
+		meta := data.Meta
+		f.printf("%s\t%s\tfeatures\t%d\n", name, k, len(data.Features))
+		f.printf("%s\t%s\tversion\t%s\n", name, k, orUnknown(meta.Version))
+		f.printf("%s\t%s\tstarted\t%s\n", name, k, fmtTime(meta.Started))
+		f.printf("%s\t%s\tfinished\t%s\n", name, k, fmtTime(meta.Finished))
+		f.printf("%s\t%s\tinstances\t%d\n", name, k, data.Model.Instances())
+		f.printf("%s\t%s\terror\t%g\n", name, k, data.Model.Error())
+		for _, file := range meta.Files {
+			f.printf("%s\t%s\tfile\t%s\t%s\n", name, k, file.Path, file.SHA256)
+		}
+		if infoFlags.config {
+			f.printf("%s\t%s\tconfig\t%s\n", name, k, orUnknown(meta.Config))
+		}
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return w.Flush()
+}
+
+func infojson(out io.Writer, name string, m *internal.Model) error {
+	st := infost{Name: name, LMs: len(m.LM)}
+	for _, k := range sortedKeys(m) {
+		data, _ := lookup(m, k)
+		meta := data.Meta
+		if !infoFlags.config {
+			meta.Config = ""
+		}
+		st.Models = append(st.Models, infomodelst{
+			Type:      k.typ,
+			Nocr:      k.nocr,
+			Features:  data.Features,
+			Meta:      meta,
+			Instances: data.Model.Instances(),
+			Error:     data.Model.Error(),
+		})
+	}
+	return json.NewEncoder(out).Encode(st)
+}
+
+type infost struct {
+	Name   string
+	LMs    int
+	Models []infomodelst
+}
+
+type infomodelst struct {
+	Type      string
+	Nocr      int
+	Features  []string
+	Meta      apoco.ModelMeta
+	Instances int
+	Error     float64
+}
+
+func fmtTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orUnknown(str string) string {
+	if str == "" {
+		return "unknown"
+	}
+	return str
+}
+
+type formater struct {
+	out io.Writer
+	err error
+}
+
+func (f *formater) printf(format string, args ...interface{}) {
+	if f.err != nil {
+		return
+	}
+	_, err := fmt.Fprintf(f.out, format, args...)
+	f.err = err
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"github.com/spf13/cobra"
+)
+
+// Cmd defines the apoco model command.
+var Cmd = &cobra.Command{
+	Use:   "model",
+	Short: "Inspect and compare models",
+}
+
+var flags = struct {
+	json bool
+}{}
+
+func init() {
+	Cmd.PersistentFlags().BoolVarP(&flags.json, "json", "J", false, "set json output")
+	// Subcommands
+	Cmd.AddCommand(infoCmd, diffCmd, exportCmd, importCmd)
+}
+
+// modelKey identifies the model data of a type and a number of OCRs.
+type modelKey struct {
+	typ  string
+	nocr int
+}
+
+func (k modelKey) String() string {
+	return fmt.Sprintf("%s/%d", k.typ, k.nocr)
+}
+
+// sortedKeys returns the sorted keys of the model data of the given
+// models.  Keys that are present in any of the models are included.
+func sortedKeys(ms ...*internal.Model) []modelKey {
+	set := make(map[modelKey]bool)
+	for _, m := range ms {
+		for typ, ds := range m.Models {
+			for nocr := range ds {
+				set[modelKey{typ, nocr}] = true
+			}
+		}
+	}
+	keys := make([]modelKey, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].typ == keys[j].typ {
+			return keys[i].nocr < keys[j].nocr
+		}
+		return keys[i].typ < keys[j].typ
+	})
+	return keys
+}
+
+// lookup returns the model data for the given key.
+func lookup(m *internal.Model, k modelKey) (internal.ModelData, bool) {
+	data, ok := m.Models[k.typ][k.nocr]
+	return data, ok
+}
+
+// weights returns the names of the features (expanded for the
+// different OCRs) and their according weights.
+func weights(k modelKey, data internal.ModelData) ([]string, []float64, error) {
+	fs, err := apoco.NewFeatureSet(data.Features...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("weights %s: %v", k, err)
+	}
+	names := fs.Names(data.Features, k.typ, k.nocr)
+	ws := data.Model.Weights()
+	if len(names) != len(ws) {
+		return nil, nil, fmt.Errorf("weights %s: bad feature names", k)
+	}
+	return names, ws, nil
+}
+
+func chk(err error) {
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
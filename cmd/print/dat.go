@@ -3,6 +3,7 @@ package print
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -20,11 +21,20 @@ var datCmd = &cobra.Command{
 	Run:   run,
 	Long: `
 Prints the data for gnuplot from FILES. Reads
-from stdin, if no FILES.`,
+from stdin, if no FILES.
+
+Types:
+  acc  accuracy of the documents and correction runs
+  err  relative error types and causes of the documents
+  pr   precision and recall of the corrections over thresholds
+  year accuracy of the correction runs by document year
+
+If the format is svg, the according plot is printed as SVG.`,
 }
 
 var datFlags = struct {
 	typ      string
+	format   string
 	replace  []string
 	limit    int
 	year     int
@@ -32,7 +42,8 @@ var datFlags = struct {
 }{}
 
 func init() {
-	datCmd.Flags().StringVarP(&datFlags.typ, "type", "t", "acc", "set type of evaluation")
+	datCmd.Flags().StringVarP(&datFlags.typ, "type", "t", "acc", "set type of evaluation (acc, err, pr or year)")
+	datCmd.Flags().StringVarP(&datFlags.format, "format", "f", "dat", "set output format (dat or svg)")
 	datCmd.Flags().StringSliceVarP(&datFlags.replace, "substitute", "e", nil,
 		"set expressions applied to file names (sed s/// syntax)")
 	datCmd.Flags().BoolVarP(&datFlags.noshorts, "noshort", "s", false,
@@ -44,21 +55,30 @@ func init() {
 }
 
 func run(_ *cobra.Command, args []string) {
+	switch datFlags.format {
+	case "dat", "svg":
+	default:
+		chk(fmt.Errorf("bad format: %s", datFlags.format))
+	}
+	svg := datFlags.format == "svg"
 	switch datFlags.typ {
-	case "acc":
+	case "acc", "year":
 		replacer, err := newReplacer(datFlags.replace)
 		chk(err)
-		acc{replacer, datFlags.noshorts}.run(args)
+		acc{replacer, datFlags.noshorts, svg, datFlags.typ == "year"}.run(args)
 	case "err":
-		err{datFlags.limit, datFlags.noshorts}.run(args)
+		err{datFlags.limit, datFlags.noshorts, svg}.run(args)
+	case "pr":
+		pr{datFlags.noshorts, svg}.run(args)
 	default:
 		panic("bad type: " + datFlags.typ)
 	}
 }
 
 type acc struct {
-	replacer replacer
-	noshorts bool
+	replacer   replacer
+	noshorts   bool
+	svg, years bool
 }
 
 func (a acc) run(files []string) {
@@ -102,8 +122,17 @@ func (a acc) run(files []string) {
 			max = len(data[year])
 		}
 	}
+	if a.years {
+		a.printYears(data, years, max)
+		return
+	}
+	if a.svg {
+		a.plot(data, years, max, "").writeBars(os.Stdout)
+		return
+	}
 
-	for i := 0; i < max; i++ {
+	p := a.plot(data, years, max, "")
+	for i := range p.xs {
 		if i == 0 {
 			fmt.Print("#")
 			for _, name := range years {
@@ -111,9 +140,67 @@ func (a acc) run(files []string) {
 			}
 			fmt.Println()
 		}
-		fmt.Printf("%q", data[years[0]][i].name)
-		for _, year := range years {
-			fmt.Printf(" %g", 1-data[year][i].data)
+		fmt.Printf("%q", p.xs[i])
+		for _, s := range p.series {
+			fmt.Printf(" %g", s.vals[i])
+		}
+		fmt.Println()
+	}
+}
+
+// plot returns the plot of the accuracies.  The x values are the
+// names of the correction runs and each year forms a series.  The
+// names are taken from the longest series; shorter series are padded
+// with NaN.
+func (a acc) plot(data map[string][]accPair, years []string, max int, xlabel string) plot {
+	p := plot{title: "Accuracy", xlabel: xlabel, ylabel: "accuracy"}
+	for _, year := range years {
+		if len(data[year]) == max {
+			for _, pair := range data[year] {
+				p.xs = append(p.xs, pair.name)
+			}
+			break
+		}
+	}
+	for _, year := range years {
+		s := series{name: year, vals: make([]float64, max)}
+		for i := range s.vals {
+			s.vals[i] = math.NaN()
+			if i < len(data[year]) {
+				s.vals[i] = 1 - data[year][i].data
+			}
+		}
+		p.series = append(p.series, s)
+	}
+	return p
+}
+
+// printYears prints the accuracies of the correction runs by year.
+func (a acc) printYears(data map[string][]accPair, years []string, max int) {
+	p := a.plot(data, years, max, "")
+	// Transpose the plot: the years are the x values and each
+	// correction run forms a series.
+	q := plot{title: "Accuracy by year", xlabel: "year", ylabel: "accuracy", xs: years}
+	for i, name := range p.xs {
+		s := series{name: name, vals: make([]float64, len(years))}
+		for j := range years {
+			s.vals[j] = p.series[j].vals[i]
+		}
+		q.series = append(q.series, s)
+	}
+	if a.svg {
+		q.writeLines(os.Stdout)
+		return
+	}
+	fmt.Print("#")
+	for _, s := range q.series {
+		fmt.Printf(" %q", s.name)
+	}
+	fmt.Println()
+	for i, year := range q.xs {
+		fmt.Print(year)
+		for _, s := range q.series {
+			fmt.Printf(" %g", s.vals[i])
 		}
 		fmt.Println()
 	}
@@ -134,6 +221,7 @@ type accPair struct {
 type err struct {
 	limit    int
 	noshorts bool
+	svg      bool
 }
 
 const (
@@ -190,15 +278,27 @@ func (e err) print(data map[string]map[string]int) {
 	sort.Slice(years, func(i, j int) bool {
 		return years[i] < years[j]
 	})
+	names := []string{shorte, miscor, badlim, falsef, badrnk, missop, infelc}
+	if e.noshorts {
+		names = names[1:]
+	}
+	if e.svg {
+		p := plot{title: "Errors", ylabel: "relative errors", xs: names}
+		for _, y := range years {
+			s := series{name: y}
+			for _, t := range names {
+				s.vals = append(s.vals, float64(data[y][t])/float64(data[y]["total"]))
+			}
+			p.series = append(p.series, s)
+		}
+		p.writeBars(os.Stdout)
+		return
+	}
 	fmt.Printf("#")
 	for _, year := range years {
 		fmt.Printf(" %s", year)
 	}
 	fmt.Println()
-	names := []string{shorte, miscor, badlim, falsef, badrnk, missop, infelc}
-	if e.noshorts {
-		names = names[1:]
-	}
 	for _, t := range names {
 		fmt.Printf("%q", t)
 		for _, y := range years {
@@ -216,6 +316,67 @@ func (e err) print(data map[string]map[string]int) {
 	}
 }
 
+// pr calculates the precision and recall of the corrections for
+// different confidence thresholds.  A non skipped token counts as
+// corrected if its confidence exceeds the threshold.  Corrections are
+// true positives if the suggestion matches the ground-truth and the
+// OCR token does not.  Suggestions that do not change the OCR token
+// are skipped like redundant corrections in eval e2e.
+type pr struct {
+	noshorts bool
+	svg      bool
+}
+
+// prSteps is the number of threshold steps between 0 and 1.
+const prSteps = 20
+
+func (x pr) run(files []string) {
+	var tps, fps [prSteps + 1]int
+	var positives int
+	eachStok(files, func(_, _ string, _ bool, stok internal.Stok) {
+		if stok.Skipped || stok.Short && x.noshorts || stok.Sug == stok.OCR {
+			return
+		}
+		tp := stok.ErrBefore() && stok.Sug == stok.GT
+		if tp {
+			positives++
+		}
+		for i := 0; i <= prSteps; i++ {
+			if stok.Conf <= float64(i)/prSteps {
+				continue
+			}
+			if tp {
+				tps[i]++
+			} else {
+				fps[i]++
+			}
+		}
+	})
+	p := plot{title: "Precision and recall", xlabel: "threshold", ylabel: "precision/recall"}
+	precision := series{name: "precision"}
+	recall := series{name: "recall"}
+	for i := 0; i <= prSteps; i++ {
+		p.xs = append(p.xs, fmt.Sprintf("%.2f", float64(i)/prSteps))
+		precision.vals = append(precision.vals, math.NaN())
+		if tps[i]+fps[i] > 0 {
+			precision.vals[i] = float64(tps[i]) / float64(tps[i]+fps[i])
+		}
+		recall.vals = append(recall.vals, math.NaN())
+		if positives > 0 {
+			recall.vals[i] = float64(tps[i]) / float64(positives)
+		}
+	}
+	p.series = []series{precision, recall}
+	if x.svg {
+		p.writeLines(os.Stdout)
+		return
+	}
+	fmt.Println("# threshold precision recall")
+	for i := range p.xs {
+		fmt.Printf("%s %g %g\n", p.xs[i], precision.vals[i], recall.vals[i])
+	}
+}
+
 func eachStok(files []string, f func(string, string, bool, internal.Stok)) {
 	if len(files) == 0 {
 		eachStokReader(os.Stdin, f)
@@ -16,8 +16,8 @@ import (
 )
 
 var protocolFlags = struct {
-	mets string
-	ifgs []string
+	mets, pages string
+	ifgs        []string
 }{}
 
 var protocolCmd = &cobra.Command{
@@ -29,6 +29,8 @@ var protocolCmd = &cobra.Command{
 func init() {
 	protocolCmd.Flags().StringVarP(&protocolFlags.mets, "mets", "m", "mets.xml", "set path to the mets file")
 	protocolCmd.Flags().StringSliceVarP(&protocolFlags.ifgs, "input-file-grp", "I", nil, "set input file groups")
+	protocolCmd.Flags().StringVar(&protocolFlags.pages, "page-id", "",
+		"only print the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
 }
 
 func runProtocol(_ *cobra.Command, args []string) {
@@ -36,15 +38,17 @@ func runProtocol(_ *cobra.Command, args []string) {
 	case len(protocolFlags.ifgs) == 0:
 		aipocoto(args)
 	default:
-		ifgs(protocolFlags.mets, protocolFlags.ifgs)
+		ifgs(protocolFlags.mets, protocolFlags.pages, protocolFlags.ifgs)
 	}
 }
 
-func ifgs(METS string, ifgs []string) {
+func ifgs(METS, spec string, ifgs []string) {
 	m, err := mets.Open(METS)
 	chk(err)
+	pages, err := m.PageIDs(spec)
+	chk(err)
 	for _, ifg := range ifgs {
-		names, err := m.FilePathsForFileGrp(ifg)
+		names, err := m.FilePathsForFileGrp(ifg, pages...)
 		chk(err)
 		_, err = fmt.Println("#name=", ifg)
 		chk(err)
@@ -0,0 +1,307 @@
+package print
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd runs the apoco print report command.
+var reportCmd = &cobra.Command{
+	Use:   "report [FILES...]",
+	Short: "Print a HTML correction report",
+	Run:   runReport,
+	Long: `
+Prints a self-contained HTML correction report for the stoks in
+FILES.  Reads from stdin, if no FILES are given.  The report contains
+the summary of the correction stats, the breakdown of the error
+causes, the accuracy of each page (or document) and a list of the
+corrections that can be filtered by their stok type.  If PAGE files
+are given, tokens are assigned to the pages by their IDs and the
+pages are linked in the report.`,
+}
+
+var reportFlags = struct {
+	pages     []string
+	title     string
+	limit     int
+	skipShort bool
+}{}
+
+func init() {
+	reportCmd.Flags().StringSliceVarP(&reportFlags.pages, "page", "P", nil,
+		"set PAGE files for the page references")
+	reportCmd.Flags().StringVarP(&reportFlags.title, "title", "t", "apoco correction report",
+		"set the title of the report")
+	reportCmd.Flags().IntVarP(&reportFlags.limit, "limit", "L", 0,
+		"set limit for the profiler's candidate set")
+	reportCmd.Flags().BoolVarP(&reportFlags.skipShort, "noshort", "s", false,
+		"exclude short tokens (len<4) from the evaluation")
+	Cmd.AddCommand(reportCmd)
+}
+
+func runReport(_ *cobra.Command, args []string) {
+	// Map the flags onto print stats.
+	statsFlags.limit, statsFlags.skipShort = reportFlags.limit, reportFlags.skipShort
+	r := newReport(reportFlags.title, reportFlags.pages)
+	if len(args) == 0 {
+		chk(internal.EachStok(os.Stdin, r.add))
+	}
+	for _, arg := range args {
+		in, err := os.Open(arg)
+		chk(err)
+		err = internal.EachStok(in, r.add)
+		in.Close()
+		chk(err)
+	}
+	chk(reportTemplate.Execute(os.Stdout, r.data()))
+}
+
+type report struct {
+	title string
+	pages []string // Paths of the PAGE files (longest base names first).
+	stats stats
+	accs  map[string]*pageAcc
+	order []string
+	cors  []reportCorrection
+}
+
+// pageAcc holds the token errors of a page (or document).
+type pageAcc struct {
+	Name, Path            string
+	Before, After, Total  int
+	AccBefore, AccAfter   float64
+	Corrections, Improved int
+}
+
+type reportCorrection struct {
+	Page, ID, Type     string
+	OCR, Sug, GT       string
+	Conf               float64
+	Cor, ErrAfter, Err bool
+	Cands              []reportCandidate
+}
+
+type reportCandidate struct {
+	Suggestion, Details string
+}
+
+func newReport(title string, pages []string) *report {
+	pages = append([]string(nil), pages...)
+	sort.Slice(pages, func(i, j int) bool {
+		return len(pageBase(pages[i])) > len(pageBase(pages[j]))
+	})
+	return &report{title: title, pages: pages, accs: make(map[string]*pageAcc)}
+}
+
+func (r *report) add(name string, stok internal.Stok) error {
+	if r.stats.skip(stok) {
+		return nil
+	}
+	if err := r.stats.stat(stok); err != nil {
+		return err
+	}
+	page, path := r.page(name, stok)
+	acc, ok := r.accs[page]
+	if !ok {
+		acc = &pageAcc{Name: page, Path: path}
+		r.accs[page] = acc
+		r.order = append(r.order, page)
+	}
+	acc.Total++
+	if stok.ErrBefore() {
+		acc.Before++
+	}
+	if stok.ErrAfter() {
+		acc.After++
+	}
+	if stok.Skipped {
+		return nil
+	}
+	if stok.Cor {
+		acc.Corrections++
+		if stok.ErrBefore() && !stok.ErrAfter() {
+			acc.Improved++
+		}
+	}
+	cor := reportCorrection{
+		Page:     page,
+		ID:       stok.ID,
+		Type:     stok.Type().String(),
+		OCR:      stok.OCR,
+		Sug:      stok.Sug,
+		GT:       stok.GT,
+		Conf:     stok.Conf,
+		Cor:      stok.Cor,
+		ErrAfter: stok.ErrAfter(),
+		Err:      stok.ErrBefore(),
+	}
+	for _, cand := range stok.Cands {
+		sug := cand
+		if pos := strings.Index(cand, ":{"); pos != -1 {
+			sug = cand[:pos]
+		}
+		cor.Cands = append(cor.Cands, reportCandidate{Suggestion: sug, Details: cand})
+	}
+	r.cors = append(r.cors, cor)
+	return nil
+}
+
+// page returns the page and the path of the according PAGE file of
+// the stok.  If no according PAGE file exists, the name of the
+// document is used.
+func (r *report) page(name string, stok internal.Stok) (string, string) {
+	for _, page := range r.pages {
+		base := pageBase(page)
+		if strings.HasPrefix(stok.ID, base+"_") {
+			return base, page
+		}
+	}
+	return name, ""
+}
+
+func pageBase(path string) string {
+	base := filepath.Base(path)
+	return base[0 : len(base)-len(filepath.Ext(base))]
+}
+
+type reportRow struct {
+	Key   string
+	Value interface{}
+}
+
+type reportData struct {
+	Title        string
+	Summary      []reportRow
+	Causes       []reportCauses
+	Pages        []*pageAcc
+	Types        []string
+	Corrections  []reportCorrection
+	CharErrTypes []reportRow
+}
+
+type reportCauses struct {
+	Type                            string
+	Total, BadRank, BadLimit, Nocor int
+}
+
+func (r *report) data() reportData {
+	ret := reportData{Title: r.title, Corrections: r.cors}
+	// Summary table from print stats.
+	data := r.stats.data(r.title)
+	keys := make([]string, 0, len(data))
+	for key, val := range data {
+		switch val.(type) {
+		case string, map[string]charErrData:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ret.Summary = append(ret.Summary, reportRow{Key: key, Value: data[key]})
+	}
+	for _, key := range r.stats.charErrsByType.keys() {
+		before, after := r.stats.charErrsByType[key].rates()
+		ret.CharErrTypes = append(ret.CharErrTypes, reportRow{
+			Key:   internal.StokType(key).String(),
+			Value: fmt.Sprintf("%.4f / %.4f", before, after),
+		})
+	}
+	// Error causes.
+	for typ := internal.SkippedShort; typ <= internal.SuspiciousNotReplacedNotCorrectErr; typ++ {
+		if typ.Skipped() || !typ.Err() || r.stats.types[typ] == 0 {
+			continue
+		}
+		ret.Causes = append(ret.Causes, reportCauses{
+			Type:     typ.String(),
+			Total:    r.stats.types[typ],
+			BadRank:  r.stats.causes[typ][internal.BadRank],
+			BadLimit: r.stats.causes[typ][internal.BadLimit],
+			Nocor:    r.stats.causes[typ][internal.MissingCandidate],
+		})
+	}
+	// Page accuracies.
+	for _, page := range r.order {
+		acc := r.accs[page]
+		acc.AccBefore = 1 - float64(acc.Before)/float64(acc.Total)
+		acc.AccAfter = 1 - float64(acc.After)/float64(acc.Total)
+		ret.Pages = append(ret.Pages, acc)
+	}
+	// Stok types of the corrections for the filter.
+	types := make(map[string]bool)
+	for _, cor := range r.cors {
+		if !types[cor.Type] {
+			types[cor.Type] = true
+			ret.Types = append(ret.Types, cor.Type)
+		}
+	}
+	sort.Strings(ret.Types)
+	return ret
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: .2em .5em; text-align: left; }
+th { background: #eee; }
+td.num { text-align: right; }
+.ok { color: #070; }
+.err { color: #a00; }
+.cands { font-size: small; color: #555; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><th>{{.Key}}</th><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+{{if .CharErrTypes}}<h2>Char error rates by stok type (before / after)</h2>
+<table>
+{{range .CharErrTypes}}<tr><th>{{.Key}}</th><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Error causes</h2>
+<table>
+<tr><th>Type</th><th>Total</th><th>Bad rank</th><th>Bad limit</th><th>Missing correction</th></tr>
+{{range .Causes}}<tr><td>{{.Type}}</td><td class="num">{{.Total}}</td><td class="num">{{.BadRank}}</td><td class="num">{{.BadLimit}}</td><td class="num">{{.Nocor}}</td></tr>
+{{end}}</table>
+<h2>Pages</h2>
+<table>
+<tr><th>Page</th><th>Tokens</th><th>Accuracy before</th><th>Accuracy after</th><th>Corrections</th><th>Successful corrections</th></tr>
+{{range .Pages}}<tr><td>{{if .Path}}<a href="{{.Path}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td class="num">{{.Total}}</td><td class="num">{{printf "%.4f" .AccBefore}}</td><td class="num">{{printf "%.4f" .AccAfter}}</td><td class="num">{{.Corrections}}</td><td class="num">{{.Improved}}</td></tr>
+{{end}}</table>
+<h2>Corrections</h2>
+<p>
+<label for="type">Stok type:</label>
+<select id="type" onchange="filter(this.value)">
+<option value="">all</option>
+{{range .Types}}<option value="{{.}}">{{.}}</option>
+{{end}}</select>
+</p>
+<table id="corrections">
+<tr><th>Page</th><th>ID</th><th>Type</th><th>OCR</th><th>Suggestion</th><th>GT</th><th>Confidence</th><th>Candidates</th></tr>
+{{range .Corrections}}<tr data-type="{{.Type}}"><td>{{.Page}}</td><td>{{.ID}}</td><td>{{.Type}}</td><td class="{{if .Err}}err{{else}}ok{{end}}">{{.OCR}}</td><td class="{{if .ErrAfter}}err{{else}}ok{{end}}">{{if .Cor}}<b>{{.Sug}}</b>{{else}}{{.Sug}}{{end}}</td><td>{{.GT}}</td><td class="num">{{printf "%.4f" .Conf}}</td><td class="cands">{{range $i, $c := .Cands}}{{if $i}}, {{end}}<span title="{{$c.Details}}">{{$c.Suggestion}}</span>{{end}}</td></tr>
+{{end}}</table>
+<script>
+function filter(type) {
+	var rows = document.getElementById("corrections").rows;
+	for (var i = 1; i < rows.length; i++) {
+		rows[i].style.display = (type === "" || rows[i].dataset.type === type) ? "" : "none";
+	}
+}
+</script>
+</body>
+</html>
+`))
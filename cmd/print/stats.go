@@ -3,7 +3,10 @@ package print
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"text/tabwriter"
 	"unicode/utf8"
 
@@ -81,6 +84,7 @@ func statsEachStokInFile(name string) error {
 type stats struct {
 	types                                     typeMap
 	causes                                    causeMap
+	charErrsByType, charErrsByLen             charErrMap
 	before                                    internal.Stok
 	mat                                       lev.Mat
 	skippedMerges, skippedSplits              int
@@ -91,9 +95,7 @@ type stats struct {
 }
 
 func (s *stats) stat(t internal.Stok) error {
-	// Exclude short tokens from the complete evaluation if
-	// the statsFlags.skipShort option is set.
-	if statsFlags.skipShort && t.Skipped && t.Short {
+	if s.skip(t) {
 		return nil
 	}
 	typ := t.Type()
@@ -122,14 +124,17 @@ func (s *stats) stat(t internal.Stok) error {
 		s.tokenErrAfter++
 	}
 	// Gather character errors.
-	s.charTotal += utf8.RuneCountInString(t.GT)
-	s.charErrBefore += s.mat.Distance(t.OCR, t.GT)
-	switch {
-	case t.Cor:
-		s.charErrAfter += s.mat.Distance(t.Sug, t.GT)
-	default:
-		s.charErrAfter += s.mat.Distance(t.OCR, t.GT)
+	total := utf8.RuneCountInString(t.GT)
+	before := s.mat.Distance(t.OCR, t.GT)
+	after := before
+	if t.Cor {
+		after = s.mat.Distance(t.Sug, t.GT)
 	}
+	s.charTotal += total
+	s.charErrBefore += before
+	s.charErrAfter += after
+	s.charErrsByType.put(int(typ), before, after, total)
+	s.charErrsByLen.put(tokenLen(t.OCR), before, after, total)
 	// Gather errors on suspicious tokens
 	if !t.Skipped {
 		s.suspTotal++
@@ -145,6 +150,12 @@ func (s *stats) stat(t internal.Stok) error {
 	return nil
 }
 
+// skip returns true if the stok is excluded from the complete
+// evaluation (short tokens if the statsFlags.skipShort option is set).
+func (s *stats) skip(t internal.Stok) bool {
+	return statsFlags.skipShort && t.Skipped && t.Short
+}
+
 func (s *stats) output(name string, json, verbose bool) error {
 	if json {
 		s.json(name)
@@ -187,6 +198,7 @@ func (s *stats) write(name string, verbose bool) {
 		fmt.Fprintf(w, "Short errors\t%d\n", s.types[internal.SkippedShortErr])
 		fmt.Fprintf(w, "Merges\t%d\n", s.skippedMerges+s.merges)
 		fmt.Fprintf(w, "Splits\t%d\n", s.skippedSplits+s.splits)
+		s.writeCharErrs(w)
 		return
 	}
 	totalSkippedShort := s.types[internal.SkippedShort] + s.types[internal.SkippedShortErr]
@@ -234,6 +246,24 @@ func (s *stats) write(name string, verbose bool) {
 	fmt.Fprintf(w, "            ├─ bad rank\t%d\n", s.causes[internal.SuspiciousNotReplacedNotCorrectErr][internal.BadRank])
 	fmt.Fprintf(w, "            ├─ bad limit\t%d\n", s.causes[internal.SuspiciousNotReplacedNotCorrectErr][internal.BadLimit])
 	fmt.Fprintf(w, "            └─ missing corr\t%d\n", s.causes[internal.SuspiciousNotReplacedNotCorrectErr][internal.MissingCandidate])
+	s.writeCharErrs(w)
+}
+
+// writeCharErrs writes the char error rates for the different stok
+// types and token lengths.
+func (s *stats) writeCharErrs(w io.Writer) {
+	for _, key := range s.charErrsByType.keys() {
+		e := s.charErrsByType[key]
+		before, after := e.rates()
+		fmt.Fprintf(w, "Char error rate %s (before/after)\t%g/%g (%d chars)\n",
+			internal.StokType(key), before, after, e.total)
+	}
+	for _, key := range s.charErrsByLen.keys() {
+		e := s.charErrsByLen[key]
+		before, after := e.rates()
+		fmt.Fprintf(w, "Char error rate len=%s (before/after)\t%g/%g (%d chars)\n",
+			tokenLenString(key), before, after, e.total)
+	}
 }
 
 func errorRates(before, after, total int) (float64, float64) {
@@ -264,6 +294,16 @@ func (s *stats) data(name string) map[string]interface{} {
 	data["ErrorRateAfter"] = errRateAfter
 	data["CharErrorRateBefore"] = charErrRateBefore
 	data["CharErrorRateAfter"] = charErrRateAfter
+	byType := make(map[string]charErrData, len(s.charErrsByType))
+	for key, e := range s.charErrsByType {
+		byType[internal.StokType(key).String()] = e.data()
+	}
+	data["CharErrorRatesByType"] = byType
+	byLen := make(map[string]charErrData, len(s.charErrsByLen))
+	for key, e := range s.charErrsByLen {
+		byLen[tokenLenString(key)] = e.data()
+	}
+	data["CharErrorRatesByLen"] = byLen
 	data["CorrectBefore"] = corbefore
 	data["CorrectAfter"] = corafter
 	data["ErrorsBefore"] = s.tokenErrBefore
@@ -305,3 +345,72 @@ func (m *causeMap) put(typ internal.StokType, cause internal.StokCause) {
 	}
 	(*m)[typ][cause]++
 }
+
+// charErrs counts the character errors before and after the
+// correction and the total number of (ground-truth) characters.
+type charErrs struct {
+	before, after, total int
+}
+
+func (e *charErrs) rates() (float64, float64) {
+	return errorRates(e.before, e.after, e.total)
+}
+
+type charErrData struct {
+	CharErrorRateBefore, CharErrorRateAfter float64
+	CharErrorsBefore, CharErrorsAfter       int
+	TotalChars                              int
+}
+
+func (e *charErrs) data() charErrData {
+	before, after := e.rates()
+	return charErrData{
+		CharErrorRateBefore: before,
+		CharErrorRateAfter:  after,
+		CharErrorsBefore:    e.before,
+		CharErrorsAfter:     e.after,
+		TotalChars:          e.total,
+	}
+}
+
+type charErrMap map[int]*charErrs
+
+func (m *charErrMap) put(key, before, after, total int) {
+	if *m == nil {
+		*m = make(charErrMap)
+	}
+	if (*m)[key] == nil {
+		(*m)[key] = &charErrs{}
+	}
+	(*m)[key].before += before
+	(*m)[key].after += after
+	(*m)[key].total += total
+}
+
+func (m charErrMap) keys() []int {
+	ret := make([]int, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// maxTokenLen is the maximal token length for the char error rates by
+// token length.  Longer tokens are counted as tokens of this length.
+const maxTokenLen = 10
+
+// tokenLen returns the (capped) length of the given OCR token.
+func tokenLen(ocr string) int {
+	if n := utf8.RuneCountInString(ocr); n < maxTokenLen {
+		return n
+	}
+	return maxTokenLen
+}
+
+func tokenLenString(n int) string {
+	if n >= maxTokenLen {
+		return strconv.Itoa(maxTokenLen) + "+"
+	}
+	return strconv.Itoa(n)
+}
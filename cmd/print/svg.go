@@ -0,0 +1,169 @@
+package print
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+)
+
+// plot holds the data of a simple plot.  Each series holds one value
+// for each x value.  Missing values are marked with NaN.
+type plot struct {
+	title, xlabel, ylabel string
+	xs                    []string
+	series                []series
+}
+
+type series struct {
+	name string
+	vals []float64
+}
+
+// Dimensions of the plots.
+const (
+	svgWidth, svgHeight                         = 800, 450
+	svgTop, svgRight, svgBottom, svgLeft        = 40, 180, 60, 60
+	svgPlotWidth, svgPlotHeight                 = svgWidth - svgLeft - svgRight, svgHeight - svgTop - svgBottom
+	svgTicks                                    = 5
+	svgFont                                     = `font-family="sans-serif" font-size="12"`
+	svgLegendX, svgLegendY, svgLegendLineHeight = svgWidth - svgRight + 20, svgTop, 18
+)
+
+var svgColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+func svgColor(i int) string {
+	return svgColors[i%len(svgColors)]
+}
+
+// ymax returns the maximum of the y axis.  Values are assumed to be
+// rates, so the maximum is 1 unless larger values exist.
+func (p plot) ymax() float64 {
+	max := 1.0
+	for _, s := range p.series {
+		for _, v := range s.vals {
+			if !math.IsNaN(v) && v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+func (p plot) y(v float64) float64 {
+	return svgTop + svgPlotHeight - v/p.ymax()*svgPlotHeight
+}
+
+// writeBars writes a grouped bar chart of the plot.  Each x value
+// forms a group with one bar for each series.
+func (p plot) writeBars(w io.Writer) {
+	p.writeHeader(w)
+	group := float64(svgPlotWidth) / float64(len(p.xs))
+	bar := group * .8 / float64(len(p.series))
+	for i, x := range p.xs {
+		gx := svgLeft + float64(i)*group
+		for j, s := range p.series {
+			if math.IsNaN(s.vals[i]) {
+				continue
+			}
+			y := p.y(s.vals[i])
+			fmt.Fprintf(w, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"><title>%s %s: %g</title></rect>`+"\n",
+				gx+group*.1+float64(j)*bar, y, bar, svgTop+svgPlotHeight-y, svgColor(j),
+				html.EscapeString(s.name), html.EscapeString(x), s.vals[i])
+		}
+		p.writeXLabel(w, gx+group/2, x)
+	}
+	p.writeFooter(w, "rect")
+}
+
+// writeLines writes a line chart of the plot.  The x values are
+// equally spaced.
+func (p plot) writeLines(w io.Writer) {
+	p.writeHeader(w)
+	step := float64(svgPlotWidth)
+	if len(p.xs) > 1 {
+		step /= float64(len(p.xs) - 1)
+	}
+	x := func(i int) float64 {
+		if len(p.xs) == 1 {
+			return svgLeft + svgPlotWidth/2
+		}
+		return svgLeft + float64(i)*step
+	}
+	for j, s := range p.series {
+		fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="2" points="`, svgColor(j))
+		for i, v := range s.vals {
+			if !math.IsNaN(v) {
+				fmt.Fprintf(w, "%.2f,%.2f ", x(i), p.y(v))
+			}
+		}
+		fmt.Fprintln(w, `"/>`)
+		for i, v := range s.vals {
+			if !math.IsNaN(v) {
+				fmt.Fprintf(w, `<circle cx="%.2f" cy="%.2f" r="3" fill="%s"><title>%s %s: %g</title></circle>`+"\n",
+					x(i), p.y(v), svgColor(j), html.EscapeString(s.name), html.EscapeString(p.xs[i]), v)
+			}
+		}
+	}
+	// Skip labels if there are too many x values.
+	every := len(p.xs)/20 + 1
+	for i := range p.xs {
+		if i%every == 0 {
+			p.writeXLabel(w, x(i), p.xs[i])
+		}
+	}
+	p.writeFooter(w, "line")
+}
+
+func (p plot) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(w, `<text x="%d" y="%d" font-family="sans-serif" font-size="16" text-anchor="middle">%s</text>`+"\n",
+		svgLeft+svgPlotWidth/2, svgTop/2+5, html.EscapeString(p.title))
+	// Y axis with ticks and grid lines.
+	max := p.ymax()
+	for i := 0; i <= svgTicks; i++ {
+		v := max * float64(i) / svgTicks
+		y := p.y(v)
+		fmt.Fprintf(w, `<line x1="%d" y1="%.2f" x2="%d" y2="%.2f" stroke="#ddd"/>`+"\n",
+			svgLeft, y, svgLeft+svgPlotWidth, y)
+		fmt.Fprintf(w, `<text x="%d" y="%.2f" %s text-anchor="end">%.2f</text>`+"\n",
+			svgLeft-5, y+4, svgFont, v)
+	}
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n",
+		svgLeft, svgTop, svgLeft, svgTop+svgPlotHeight)
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n",
+		svgLeft, svgTop+svgPlotHeight, svgLeft+svgPlotWidth, svgTop+svgPlotHeight)
+	fmt.Fprintf(w, `<text x="%d" y="%d" %s text-anchor="middle">%s</text>`+"\n",
+		svgLeft+svgPlotWidth/2, svgHeight-10, svgFont, html.EscapeString(p.xlabel))
+	fmt.Fprintf(w, `<text x="15" y="%d" %s text-anchor="middle" transform="rotate(-90 15 %d)">%s</text>`+"\n",
+		svgTop+svgPlotHeight/2, svgFont, svgTop+svgPlotHeight/2, html.EscapeString(p.ylabel))
+}
+
+func (p plot) writeXLabel(w io.Writer, x float64, label string) {
+	fmt.Fprintf(w, `<text x="%.2f" y="%d" %s text-anchor="middle">%s</text>`+"\n",
+		x, svgTop+svgPlotHeight+18, svgFont, html.EscapeString(label))
+}
+
+// writeFooter writes the legend (using the given kind of markers) and
+// closes the svg element.
+func (p plot) writeFooter(w io.Writer, kind string) {
+	for j, s := range p.series {
+		y := svgLegendY + j*svgLegendLineHeight
+		switch kind {
+		case "rect":
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n",
+				svgLegendX, y, svgColor(j))
+		default:
+			fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"/>`+"\n",
+				svgLegendX, y+6, svgLegendX+12, y+6, svgColor(j))
+		}
+		fmt.Fprintf(w, `<text x="%d" y="%d" %s>%s</text>`+"\n",
+			svgLegendX+18, y+11, svgFont, html.EscapeString(s.name))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
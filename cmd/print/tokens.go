@@ -13,7 +13,7 @@ import (
 
 var tokensFlags = struct {
 	ifgs, extensions []string
-	mets             string
+	mets, pages      string
 	normalize, gt    bool
 }{}
 
@@ -29,6 +29,8 @@ func init() {
 	tokensCmd.Flags().StringSliceVarP(&tokensFlags.extensions, "extensions", "e", []string{".xml"},
 		"set input file extensions")
 	tokensCmd.Flags().StringVarP(&tokensFlags.mets, "mets", "m", "mets.xml", "set path to the mets file")
+	tokensCmd.Flags().StringVar(&tokensFlags.pages, "page-id", "",
+		"only print the given pages (comma separated ids or ranges PHYS_0001..PHYS_0005)")
 	tokensCmd.Flags().BoolVarP(&tokensFlags.normalize, "normalize", "N", false, "normalize tokens")
 	tokensCmd.Flags().BoolVarP(&tokensFlags.gt, "gt", "g", false, "enable ground-truth data")
 }
@@ -44,10 +46,11 @@ func runTokens(_ *cobra.Command, args []string) {
 		stream = append(stream, cat(tokensFlags.gt))
 	}
 	p := internal.Piper{
-		METS: tokensFlags.mets,
-		IFGS: tokensFlags.ifgs,
-		Exts: tokensFlags.extensions,
-		Dirs: args,
+		METS:  tokensFlags.mets,
+		IFGS:  tokensFlags.ifgs,
+		Pages: tokensFlags.pages,
+		Exts:  tokensFlags.extensions,
+		Dirs:  args,
 	}
 	chk(p.Pipe(context.Background(), stream...))
 }
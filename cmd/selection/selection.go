@@ -0,0 +1,282 @@
+package selection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~flobar/apoco/cmd/internal"
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Cmd defines the apoco select command.
+var Cmd = &cobra.Command{
+	Use:   "select [DIRS...]",
+	Short: "Select lines for the transcription of ground-truth",
+	Long: `
+Runs the correction pipeline on the (un-annotated) documents in DIRS
+and ranks the lines by the uncertainty of the models.  The
+uncertainty of a suspicious token is the weighted mean of the
+decision maker's uncertainty (confidence near the threshold), the
+re-ranker's uncertainty (small confidence difference between the
+best and the next ranked candidate) and the disagreement of the
+OCRs.  The uncertainty of a line is the maximal uncertainty of its
+tokens.
+
+The lines are selected greedily.  To spread the selection over the
+documents, the uncertainties of the remaining lines of a document
+are multiplied with the decay factor for each of its already
+selected lines.  For each selected line, the file (the snippet file
+of the master OCR or the PAGE XML file), the line ID, the
+uncertainty and the suspicious tokens are printed.`,
+	Run: run,
+}
+
+var flags = struct {
+	extensions             []string
+	weights                []float64
+	parameter, model       string
+	nocr, top              int
+	decay                  float64
+	cache, alev, amsa      bool
+	ocands, hyphens, words bool
+	json                   bool
+}{}
+
+func init() {
+	Cmd.Flags().StringVarP(&flags.parameter, "parameter", "p", "config.toml",
+		"set the path to the configuration file")
+	Cmd.Flags().StringSliceVarP(&flags.extensions, "extensions", "e", []string{".xml"},
+		"set the input file extensions")
+	Cmd.Flags().StringVarP(&flags.model, "model", "M", "",
+		"set the model path (overwrites the setting in the configuration file)")
+	Cmd.Flags().IntVarP(&flags.nocr, "nocr", "n", 0,
+		"set the number of parallel OCRs (overwrites the setting in the configuration file)")
+	Cmd.Flags().BoolVarP(&flags.cache, "cache", "c", false,
+		"enable caching of profiles (overwrites the setting in the configuration file)")
+	Cmd.Flags().BoolVarP(&flags.alev, "alignlev", "v", false,
+		"align using Levenshtein (matrix) alignment")
+	Cmd.Flags().BoolVarP(&flags.amsa, "alignmsa", "S", false,
+		"align using multiple sequence alignment (overwrites --alignlev)")
+	Cmd.Flags().BoolVarP(&flags.ocands, "ocrcands", "u", false,
+		"use the support OCR tokens as additional candidates")
+	Cmd.Flags().BoolVarP(&flags.hyphens, "hyphens", "H", false,
+		"join hyphenated tokens at the end of lines")
+	Cmd.Flags().IntVarP(&flags.top, "top", "k", 50,
+		"set the number of selected lines (or tokens)")
+	Cmd.Flags().Float64VarP(&flags.decay, "decay", "D", .5,
+		"set the decay factor for the documents of selected lines (1 disables the diversity)")
+	Cmd.Flags().Float64SliceVarP(&flags.weights, "weights", "w", []float64{1, 1, 1},
+		"set the weights of the dm, rr and ocr uncertainties")
+	Cmd.Flags().BoolVarP(&flags.words, "tokens", "t", false, "select single tokens instead of lines")
+	Cmd.Flags().BoolVarP(&flags.json, "json", "J", false, "set json output")
+}
+
+func run(_ *cobra.Command, args []string) {
+	c, err := internal.ReadConfig(flags.parameter)
+	chk(err)
+
+	internal.UpdateInConfig(&c.Model, flags.model)
+	internal.UpdateInConfig(&c.Nocr, flags.nocr)
+	internal.UpdateInConfig(&c.Cache, flags.cache)
+	internal.UpdateInConfig(&c.AlignLev, flags.alev)
+	internal.UpdateInConfig(&c.AlignMSA, flags.amsa)
+	internal.UpdateInConfig(&c.OCRCands, flags.ocands)
+	internal.UpdateInConfig(&c.Hyphens, flags.hyphens)
+
+	if len(flags.weights) != 3 {
+		chk(fmt.Errorf("bad weights: %v", flags.weights))
+	}
+	m, err := internal.ReadModel(c.Model, c.LM, false)
+	chk(err)
+	rrlr, rrfs, err := m.Get("rr", c.Nocr)
+	chk(err)
+	dmlr, dmfs, err := m.Get("dm", c.Nocr)
+	chk(err)
+	p := internal.Piper{
+		Exts:     flags.extensions,
+		Dirs:     args,
+		AlignLev: c.AlignLev,
+		AlignMSA: c.AlignMSA,
+		Lines:    true,
+	}
+	s := selector{weights: flags.weights, lines: !flags.words, items: make(map[key]*item)}
+	chk(p.Pipe(
+		context.Background(),
+		apoco.FilterBad(c.Nocr),
+		internal.JoinHyphenations(c),
+		apoco.Normalize(),
+		apoco.FilterShort(4),
+		apoco.ConnectLanguageModel(m.LM),
+		apoco.ConnectUnigrams(),
+		internal.ConnectProfile(c, "-profiler.json.gz"),
+		internal.FilterLex(c),
+		apoco.ConnectCandidates(),
+		internal.ConnectOCRCandidates(c),
+		apoco.ConnectRankings(rrlr, rrfs, c.Nocr),
+		s.collect(dmlr, dmfs, c.Nocr),
+	))
+	chk(write(os.Stdout, s.selectItems(flags.top, flags.decay)))
+}
+
+// threshold is the decision maker's threshold for corrections (see
+// apoco correct).
+const threshold = 0.5
+
+type key struct {
+	file, id string
+}
+
+// item is a line (or a token) with the uncertainties of its most
+// uncertain token.
+type item struct {
+	Document, File, ID string
+	Score, DM, RR, OCR float64
+	Tokens             []string // Suspicious (master OCR) tokens.
+}
+
+type selector struct {
+	weights []float64
+	lines   bool
+	items   map[key]*item
+	order   []key
+}
+
+// collect returns a stream function that calculates the decision
+// maker's confidences for the ranked tokens and adds the tokens'
+// uncertainties to the items of the selector.
+func (s *selector) collect(p ml.Predictor, fs apoco.FeatureSet, nocr int) apoco.StreamFunc {
+	return func(ctx context.Context, in <-chan apoco.T, _ chan<- apoco.T) error {
+		var xs []float64
+		var ts []apoco.T
+		err := apoco.EachToken(ctx, in, func(t apoco.T) error {
+			xs = fs.Calculate(xs, t, nocr)
+			ts = append(ts, t)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("select: %v", err)
+		}
+		if len(ts) == 0 {
+			return nil
+		}
+		ps := p.Predict(mat.NewDense(len(ts), len(xs)/len(ts), xs))
+		for i := range ts {
+			s.add(ts[i], ps.AtVec(i), nocr)
+		}
+		return nil
+	}
+}
+
+func (s *selector) add(t apoco.T, conf float64, nocr int) {
+	dm := 1 - math.Abs(conf-threshold)/math.Max(threshold, 1-threshold)
+	diff, _ := apoco.RankingConfDiffToNext(t, 0, nocr)
+	rr := 1 - math.Min(1, math.Max(0, diff))
+	var ocr float64
+	if nocr > 1 {
+		for i := 1; i < nocr; i++ {
+			if t.Tokens[i] != t.Tokens[0] {
+				ocr++
+			}
+		}
+		ocr /= float64(nocr - 1)
+	}
+	score := (s.weights[0]*dm + s.weights[1]*rr + s.weights[2]*ocr) /
+		(s.weights[0] + s.weights[1] + s.weights[2])
+	k := key{file: t.File, id: t.ID}
+	if s.lines {
+		k.id = lineID(t.ID)
+	}
+	x, ok := s.items[k]
+	if !ok {
+		x = &item{File: k.file, ID: k.id, Score: -1}
+		if t.Document != nil {
+			x.Document = t.Document.Group
+		}
+		s.items[k] = x
+		s.order = append(s.order, k)
+	}
+	x.Tokens = append(x.Tokens, t.Tokens[0])
+	if score > x.Score {
+		x.Score, x.DM, x.RR, x.OCR = score, dm, rr, ocr
+	}
+}
+
+// lineID returns the ID of the line of the given token ID (see
+// pagexml.LineTokenID).
+func lineID(id string) string {
+	if i := strings.LastIndex(id, ":"); i != -1 {
+		return id[:i]
+	}
+	return id
+}
+
+// selectItems greedily selects the top k items.  The scores of the
+// remaining items of a document are multiplied with the decay factor
+// for each already selected item of the document.
+func (s *selector) selectItems(k int, decay float64) []*item {
+	docs := make(map[string][]*item)
+	var names []string
+	for _, key := range s.order {
+		x := s.items[key]
+		if _, ok := docs[x.Document]; !ok {
+			names = append(names, x.Document)
+		}
+		docs[x.Document] = append(docs[x.Document], x)
+	}
+	for _, name := range names {
+		xs := docs[name]
+		sort.SliceStable(xs, func(i, j int) bool {
+			return xs[i].Score > xs[j].Score
+		})
+	}
+	var ret []*item
+	picked := make(map[string]int)
+	for len(ret) < k {
+		best, max := "", -1.0
+		for _, name := range names {
+			if len(docs[name]) == 0 {
+				continue
+			}
+			score := docs[name][0].Score * math.Pow(decay, float64(picked[name]))
+			if score > max {
+				best, max = name, score
+			}
+		}
+		if max < 0 {
+			break
+		}
+		ret = append(ret, docs[best][0])
+		docs[best] = docs[best][1:]
+		picked[best]++
+	}
+	return ret
+}
+
+func write(out io.Writer, items []*item) error {
+	if flags.json {
+		return json.NewEncoder(out).Encode(items)
+	}
+	for _, x := range items {
+		_, err := fmt.Fprintf(out, "%s\t%s\t%f\t%s\n", x.File, x.ID, x.Score, strings.Join(x.Tokens, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chk(err error) {
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
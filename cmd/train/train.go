@@ -3,6 +3,9 @@ package train
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"git.sr.ht/~flobar/apoco/cmd/internal"
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
@@ -54,22 +58,37 @@ func train(_ *cobra.Command, args []string) {
 
 	learn, ntrain, fn, err := getTrainingParams(c)
 	chk(err)
+	started := time.Now()
 	lr := &ml.LR{LearningRate: learn, Ntrain: ntrain}
+	var files []apoco.TrainingFile
 	for _, name := range args {
-		fitFile(c, fn, lr, name)
+		files = append(files, fitFile(c, fn, lr, name))
 	}
+	config, err := json.Marshal(c)
+	chk(err)
 
 	m, err := internal.ReadModel(c.Model, c.LM, false)
 	chk(err)
 	m.Put(flags.typ, c.Nocr, lr, fn)
+	chk(m.SetMeta(flags.typ, c.Nocr, apoco.ModelMeta{
+		Version:  internal.Version,
+		Config:   string(config),
+		Files:    files,
+		Started:  started,
+		Finished: time.Now(),
+	}))
 	chk(m.Write(c.Model))
 }
 
-func fitFile(c *internal.Config, fn []string, f ml.Fitter, name string) {
+// fitFile fits the model with the training data of the given file
+// and returns the file's path together with the hash of its content.
+func fitFile(c *internal.Config, fn []string, f ml.Fitter, name string) apoco.TrainingFile {
 	r, err := os.Open(name)
 	chk(err)
 	defer r.Close()
-	fit(c, fn, f, r)
+	h := sha256.New()
+	fit(c, fn, f, io.TeeReader(r, h))
+	return apoco.TrainingFile{Path: name, SHA256: hex.EncodeToString(h.Sum(nil))}
 }
 
 func fit(c *internal.Config, fn []string, f ml.Fitter, r io.Reader) {
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 
+	"git.sr.ht/~flobar/apoco/cmd/ablate"
 	"git.sr.ht/~flobar/apoco/cmd/align"
+	"git.sr.ht/~flobar/apoco/cmd/compare"
 	"git.sr.ht/~flobar/apoco/cmd/correct"
 	"git.sr.ht/~flobar/apoco/cmd/csv"
 	"git.sr.ht/~flobar/apoco/cmd/eval"
+	"git.sr.ht/~flobar/apoco/cmd/model"
 	"git.sr.ht/~flobar/apoco/cmd/print"
 	"git.sr.ht/~flobar/apoco/cmd/profile"
+	"git.sr.ht/~flobar/apoco/cmd/selection"
 	"git.sr.ht/~flobar/apoco/cmd/train"
 	"git.sr.ht/~flobar/apoco/cmd/version"
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
@@ -28,17 +34,29 @@ var logLevel string
 func init() {
 	root.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "INFO", "set log level")
 	root.AddCommand(
+		ablate.Cmd,
 		align.Cmd,
+		correct.ApplyCmd,
+		compare.Cmd,
 		correct.Cmd,
 		csv.Cmd,
 		eval.Cmd,
+		model.Cmd,
+		correct.OCRDCmd,
 		print.Cmd,
 		profile.Cmd,
+		correct.RevertCmd,
+		selection.Cmd,
 		train.Cmd,
 		version.Cmd,
+		correct.VoteCmd,
 	)
 }
 
 func main() {
+	// Run the OCR-D processor interface if called as OCR-D processor.
+	if filepath.Base(os.Args[0]) == correct.OCRDExecutable {
+		root.SetArgs(append([]string{"ocrd"}, os.Args[1:]...))
+	}
 	root.Execute()
 }
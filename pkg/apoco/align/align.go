@@ -34,6 +34,30 @@ func (p Pos) String() string {
 	return string(p.Slice())
 }
 
+// Mode defines the alignment method.
+type Mode int
+
+// Alignment modes.
+const (
+	ModeDo  Mode = iota // Pairwise alignment using the primary's whitespace (see Do).
+	ModeLev             // Pairwise Levenshtein alignment (see Lev).
+	ModeMSA             // Progressive multiple sequence alignment (see MSA).
+)
+
+// Align aligns the words in primary with the words in rest using the
+// according alignment method.  The matrix is not used by ModeDo and
+// can be nil in this case.
+func (mode Mode) Align(m *lev.Mat, primary []rune, rest ...[]rune) [][]Pos {
+	switch mode {
+	case ModeLev:
+		return Lev(m, primary, rest...)
+	case ModeMSA:
+		return MSA(m, primary, rest...)
+	default:
+		return Do(primary, rest...)
+	}
+}
+
 // Do aligns the words in master pairwise with the words in other.
 func Do(master []rune, other ...[]rune) [][]Pos {
 	var spaces []int
@@ -216,3 +240,95 @@ func stripR(str []rune) []rune {
 	b, e := strip(0, len(str), str)
 	return str[b:e]
 }
+
+// Score holds quality measures of word alignments.  The primary
+// tokens are compared with all secondary alignments.
+type Score struct {
+	Tokens  int // Number of aligned primary tokens.
+	Empty   int // Number of empty secondary alignments of non-empty primary tokens.
+	Merged  int // Number of secondary alignments shared with the previous primary token (see RateTokens).
+	Chars   int // Number of compared characters.
+	Matches int // Number of agreeing characters.
+}
+
+// Agreement returns the character agreement of the alignments, i.e.
+// the ratio of agreeing characters.  If no characters were compared,
+// 1 is returned.
+func (s Score) Agreement() float64 {
+	if s.Chars == 0 {
+		return 1
+	}
+	return float64(s.Matches) / float64(s.Chars)
+}
+
+// Add adds the counts of o to s.
+func (s *Score) Add(o Score) {
+	s.Tokens += o.Tokens
+	s.Empty += o.Empty
+	s.Merged += o.Merged
+	s.Chars += o.Chars
+	s.Matches += o.Matches
+}
+
+// Rate calculates the score of the given alignments (see Do and
+// Lev).  Secondary alignments are merged if they span the same slice
+// as the secondary alignment of the previous primary token.
+func Rate(m *lev.Mat, pos [][]Pos) Score {
+	tokens := make([][][]rune, len(pos))
+	for i := range pos {
+		tokens[i] = make([][]rune, len(pos[i]))
+		for j := range pos[i] {
+			tokens[i][j] = pos[i][j].Slice()
+		}
+	}
+	return rate(m, tokens, func(i, j int) bool {
+		return pos[i][j].B == pos[i-1][j].B && pos[i][j].E == pos[i-1][j].E
+	})
+}
+
+// RateTokens calculates the score of already aligned tokens.  Each
+// tokens[i] holds the primary token followed by its secondary
+// alignments.  Without positions, secondary alignments are merged if
+// they are equal to the secondary alignment of the previous primary
+// token.  Repeated words like "die die" are therefore counted as
+// merges; use Rate to distinguish them by their spans.
+func RateTokens(m *lev.Mat, tokens [][]string) Score {
+	rs := make([][][]rune, len(tokens))
+	for i := range tokens {
+		rs[i] = make([][]rune, len(tokens[i]))
+		for j := range tokens[i] {
+			rs[i][j] = []rune(tokens[i][j])
+		}
+	}
+	return rate(m, rs, func(i, j int) bool {
+		return tokens[i][j] == tokens[i-1][j]
+	})
+}
+
+func rate(m *lev.Mat, tokens [][][]rune, merged func(i, j int) bool) Score {
+	var s Score
+	for i := range tokens {
+		if len(tokens[i]) == 0 {
+			continue
+		}
+		s.Tokens++
+		p := tokens[i][0]
+		for j := 1; j < len(tokens[i]); j++ {
+			o := tokens[i][j]
+			if len(o) == 0 {
+				if len(p) > 0 {
+					s.Empty++
+				}
+			} else if i > 0 && j < len(tokens[i-1]) && merged(i, j) {
+				s.Merged++
+			}
+			max := len(p)
+			if len(o) > max {
+				max = len(o)
+			}
+			s.Chars += max
+			s.Matches += max - m.DistanceR(p, o)
+		}
+	}
+	return s
+}
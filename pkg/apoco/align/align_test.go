@@ -1,6 +1,7 @@
 package align
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -110,3 +111,111 @@ func TestLev(t *testing.T) {
 		})
 	}
 }
+
+func TestRate(t *testing.T) {
+	for _, tc := range []struct {
+		master, other string
+		want          Score
+	}{
+		{"", "", Score{Tokens: 1}},
+		{"ab cd", "ab cd", Score{Tokens: 2, Chars: 4, Matches: 4}},
+		{"ab cd", "ab cx", Score{Tokens: 2, Chars: 4, Matches: 3}},
+		{"ab cd", "abcd", Score{Tokens: 2, Merged: 1, Chars: 8, Matches: 4}},
+		{"ab cd", "", Score{Tokens: 2, Empty: 2, Chars: 4}},
+		{"die die", "die die", Score{Tokens: 2, Chars: 6, Matches: 6}},
+	} {
+		t.Run(tc.master+"|"+tc.other, func(t *testing.T) {
+			var m lev.Mat
+			if got := Rate(&m, Do([]rune(tc.master), []rune(tc.other))); got != tc.want {
+				t.Fatalf("expected %+v; got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRateTokens(t *testing.T) {
+	for _, tc := range []struct {
+		tokens    [][]string
+		want      Score
+		agreement float64
+	}{
+		{nil, Score{}, 1},
+		{[][]string{{"ab", "ab", "ab"}}, Score{Tokens: 1, Chars: 4, Matches: 4}, 1},
+		{[][]string{{"ab", "abcd"}, {"cd", "abcd"}}, Score{Tokens: 2, Merged: 1, Chars: 8, Matches: 4}, .5},
+		{[][]string{{"abcd", ""}}, Score{Tokens: 1, Empty: 1, Chars: 4}, 0},
+		// Repeated words cannot be told apart from merges.
+		{[][]string{{"die", "die"}, {"die", "die"}}, Score{Tokens: 2, Merged: 1, Chars: 6, Matches: 6}, 1},
+	} {
+		t.Run(fmt.Sprintf("%v", tc.tokens), func(t *testing.T) {
+			var m lev.Mat
+			got := RateTokens(&m, tc.tokens)
+			if got != tc.want {
+				t.Fatalf("expected %+v; got %+v", tc.want, got)
+			}
+			if got := got.Agreement(); got != tc.agreement {
+				t.Fatalf("expected agreement %g; got %g", tc.agreement, got)
+			}
+		})
+	}
+}
+
+func TestMSA(t *testing.T) {
+	for _, tc := range []struct {
+		test []string
+		want []string // Nil if the result equals the pairwise Levenshtein alignment.
+	}{
+		{[]string{"", ""}, nil},
+		{[]string{"", "A B"}, nil},
+		{[]string{"T", ""}, nil},
+		{[]string{"ab cd", "ab cd"}, nil},
+		{[]string{"ab cd", "abcd"}, nil},
+		{[]string{"abcd", "ab cd"}, nil},
+		{[]string{" ab  cd  ", "ab cd"}, nil},
+		{[]string{"n uch ter in", "nuchter in"}, nil},
+		{[]string{"a bc  d", "a b d"}, nil},
+		{[]string{"ab cd ef", "ab cdef", "abcd ef", "ab cd ef"}, nil},
+		{[]string{"ab x cd", "ab cd"}, []string{"ab", "ab", "x", "", "cd", "cd"}},
+		{[]string{"ab cd", "ab x cd"}, []string{"ab", "ab x", "cd", "cd"}},
+		{
+			[]string{" H ergen ser g i eß u n g en", "  er;en oer g ieß u n gen", "Herzengießungen"},
+			[]string{
+				"H", "", "Herzengießungen",
+				"ergen", "er;en", "Herzengießungen",
+				"ser", "oer", "Herzengießungen",
+				"g", "g", "Herzengießungen",
+				"i", "ieß", "Herzengießungen",
+				"eß", "ieß", "Herzengießungen",
+				"u", "u", "Herzengießungen",
+				"n", "n", "Herzengießungen",
+				"g", "gen", "Herzengießungen",
+				"en", "gen", "Herzengießungen",
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("%q", tc.test), func(t *testing.T) {
+			var m lev.Mat
+			rs := make([][]rune, len(tc.test))
+			for i := range tc.test {
+				rs[i] = []rune(tc.test[i])
+			}
+			got := flatten(MSA(&m, rs[0], rs[1:]...))
+			want := tc.want
+			if want == nil {
+				want = flatten(Lev(&m, rs[0], rs[1:]...))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %q; got %q", want, got)
+			}
+		})
+	}
+}
+
+func flatten(pos [][]Pos) []string {
+	var ret []string
+	for i := range pos {
+		for j := range pos[i] {
+			ret = append(ret, pos[i][j].String())
+		}
+	}
+	return ret
+}
@@ -0,0 +1,252 @@
+package align
+
+import (
+	"sort"
+	"unicode"
+
+	"git.sr.ht/~flobar/lev"
+)
+
+// gap marks a gap in a column of a multiple sequence alignment.
+const gap rune = -1
+
+// MSA aligns the words in primary with the words of all other
+// strings using a progressive multiple sequence alignment.  The other
+// strings are added to the alignment in the order of their Levenshtein
+// distance to the primary string.  Each string is aligned with the
+// columns of all previously aligned strings, which results in a
+// consistent column structure for all strings.
+//
+// As with Do and Lev, the alignments are split at the whitespace of
+// the primary string.  Each word of the other strings is assigned to
+// all primary words it overlaps with (merges).  Words that do not
+// overlap with any primary word are assigned to the preceding
+// primary word.
+func MSA(m *lev.Mat, primary []rune, rest ...[]rune) [][]Pos {
+	primary = stripR(primary)
+	strs := make([][]rune, len(rest)+1)
+	strs[0] = primary
+	for i := range rest {
+		strs[i+1] = stripR(rest[i])
+	}
+	cols := msa(m, strs)
+	// Calculate the column indices of the characters of each string.
+	colidx := make([][]int, len(strs))
+	for i := range strs {
+		colidx[i] = make([]int, 0, len(strs[i]))
+		for c := range cols {
+			if cols[c][i] != gap {
+				colidx[i] = append(colidx[i], c)
+			}
+		}
+	}
+	pwords := words(primary, colidx[0])
+	tokens := make([][]Pos, len(pwords))
+	for i, w := range pwords {
+		tokens[i] = []Pos{mkpos(w.b, w.e, primary)}
+	}
+	for k := 1; k < len(strs); k++ {
+		as := assign(pwords, words(strs[k], colidx[k]), strs[k])
+		for i := range tokens {
+			tokens[i] = append(tokens[i], as[i])
+		}
+	}
+	return tokens
+}
+
+// word represents a word in a string with its start and end positions
+// in the string and its first and last column in the alignment.
+type word struct {
+	b, e, cb, ce int
+}
+
+// words returns the (whitespace separated) words of str.  An empty
+// string consists of one empty word.
+func words(str []rune, colidx []int) []word {
+	var ret []word
+	for i := 0; i < len(str); i++ {
+		if unicode.IsSpace(str[i]) {
+			continue
+		}
+		b := i
+		for i < len(str) && !unicode.IsSpace(str[i]) {
+			i++
+		}
+		ret = append(ret, word{b: b, e: i, cb: colidx[b], ce: colidx[i-1]})
+	}
+	if len(ret) == 0 {
+		return []word{{cb: -1, ce: -1}}
+	}
+	return ret
+}
+
+// assign assigns the secondary words to the primary words and returns
+// the according alignment positions in str for each primary word.
+func assign(pwords, swords []word, str []rune) []Pos {
+	ret := make([]Pos, len(pwords))
+	for i := range ret {
+		ret[i] = Pos{B: -1, E: -1, str: str}
+	}
+	add := func(i int, w word) {
+		if ret[i].B == -1 {
+			ret[i].B = w.b
+		}
+		ret[i].E = w.e
+	}
+	for _, w := range swords {
+		if w.b == w.e { // empty string
+			continue
+		}
+		var found bool
+		for i, p := range pwords {
+			if w.cb <= p.ce && w.ce >= p.cb {
+				add(i, w)
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		// Assign to the preceding primary word (or the first one).
+		i := sort.Search(len(pwords), func(i int) bool {
+			return pwords[i].cb > w.cb
+		})
+		if i > 0 {
+			i--
+		}
+		add(i, w)
+	}
+	// Empty alignments point to the end of the previous alignment.
+	var e int
+	for i := range ret {
+		if ret[i].B == -1 {
+			ret[i].B, ret[i].E = e, e
+		}
+		e = ret[i].E
+	}
+	return ret
+}
+
+// msa progressively aligns the given strings and returns the columns
+// of the alignment.  Each column contains one rune (or a gap) for each
+// of the strings in their original order.
+func msa(m *lev.Mat, strs [][]rune) [][]rune {
+	order := make([]int, len(strs)-1)
+	dists := make([]int, len(strs))
+	for i := 1; i < len(strs); i++ {
+		order[i-1] = i
+		dists[i] = m.DistanceR(strs[0], strs[i])
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dists[order[i]] < dists[order[j]]
+	})
+	cols := make([][]rune, len(strs[0]))
+	rows := []int{0}
+	for i, r := range strs[0] {
+		cols[i] = []rune{r}
+	}
+	for _, k := range order {
+		cols = alignProfile(cols, len(rows), strs[k])
+		rows = append(rows, k)
+	}
+	// Reorder the rows of the columns to the original order.
+	ret := make([][]rune, len(cols))
+	for c := range cols {
+		ret[c] = make([]rune, len(strs))
+		for i, k := range rows {
+			ret[c][k] = cols[c][i]
+		}
+	}
+	return ret
+}
+
+// Costs for the alignment of a string with a profile.
+func subCost(col []rune, r rune) float64 {
+	var n float64
+	for _, c := range col {
+		if c != r {
+			n++
+		}
+	}
+	return n / float64(len(col))
+}
+
+func delCost(col []rune) float64 {
+	var n float64
+	for _, c := range col {
+		if c != gap {
+			n++
+		}
+	}
+	return n / float64(len(col))
+}
+
+const insCost = 1
+
+// alignProfile aligns the string str with the columns of the given
+// profile of the given number of rows and returns the new columns of
+// the alignment.  The runes of str are appended to the columns.
+func alignProfile(cols [][]rune, rows int, str []rune) [][]rune {
+	n, m := len(cols), len(str)
+	d := make([][]float64, n+1)
+	for i := range d {
+		d[i] = make([]float64, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		d[i][0] = d[i-1][0] + delCost(cols[i-1])
+	}
+	for j := 1; j <= m; j++ {
+		d[0][j] = d[0][j-1] + insCost
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			d[i][j] = min3(
+				d[i-1][j-1]+subCost(cols[i-1], str[j-1]),
+				d[i-1][j]+delCost(cols[i-1]),
+				d[i][j-1]+insCost,
+			)
+		}
+	}
+	// Trace back (prefer substitutions over deletions over
+	// insertions).
+	column := func(col []rune, r rune) []rune {
+		ret := make([]rune, rows+1)
+		if col == nil {
+			for k := range ret {
+				ret[k] = gap
+			}
+		} else {
+			copy(ret, col)
+		}
+		ret[rows] = r
+		return ret
+	}
+	var ret [][]rune
+	for i, j := n, m; i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && d[i][j] == d[i-1][j-1]+subCost(cols[i-1], str[j-1]):
+			ret = append(ret, column(cols[i-1], str[j-1]))
+			i, j = i-1, j-1
+		case i > 0 && d[i][j] == d[i-1][j]+delCost(cols[i-1]):
+			ret = append(ret, column(cols[i-1], gap))
+			i--
+		default:
+			ret = append(ret, column(nil, str[j-1]))
+			j--
+		}
+	}
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret
+}
+
+func min3(a, b, c float64) float64 {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -51,6 +51,8 @@ var register = map[string]func([]string) (FeatureFunc, error){
 	"CandidateMinTrigramFreq":        mkCandidateMinTrigramFreq,
 	"CandidateLen":                   _ff(CandidateLen),
 	"CandidateMatchesOCR":            _ff(CandidateMatchesOCR),
+	"CandidateIsOCRCandidate":        _ff(CandidateIsOCRCandidate),
+	"CandidateIsOCRReading":          _ff(CandidateIsOCRReading),
 	"RankingConf":                    _ff(RankingConf),
 	"RankingConfDiffToNext":          _ff(RankingConfDiffToNext),
 	"RankingCandidateConfDiffToNext": _ff(RankingCandidateConfDiffToNext),
@@ -504,6 +506,36 @@ func CandidateMatchesOCR(t T, i, n int) (float64, bool) {
 	return ml.Bool(candidate.Suggestion == t.Tokens[i]), true
 }
 
+// CandidateIsOCRCandidate returns true if the connected candidate was
+// generated from the support OCR tokens (see ConnectOCRCandidates).
+func CandidateIsOCRCandidate(t T, i, n int) (float64, bool) {
+	if i != 0 {
+		return 0, false
+	}
+	candidate := mustGetCandidate(t)
+	return ml.Bool(candidate.Dict == OCRCandidateDict), true
+}
+
+// CandidateIsOCRReading returns true if the connected candidate was
+// generated from the support OCR tokens and is the reading of one of
+// the support OCR tokens (and not one of their profiler
+// interpretations).
+func CandidateIsOCRReading(t T, i, n int) (float64, bool) {
+	if i != 0 {
+		return 0, false
+	}
+	candidate := mustGetCandidate(t)
+	if candidate.Dict != OCRCandidateDict {
+		return ml.False, true
+	}
+	for j := 1; j < n; j++ {
+		if t.Tokens[j] == candidate.Suggestion {
+			return ml.True, true
+		}
+	}
+	return ml.False, true
+}
+
 // OCRLevDist returns the levenshtein distance between the
 // secondary OCRs with the primary OCR.
 func OCRLevDist(t T, i, n int) (float64, bool) {
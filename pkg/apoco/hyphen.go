@@ -0,0 +1,128 @@
+package apoco
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"git.sr.ht/~flobar/lev"
+)
+
+// hyphens lists the runes that are used to hyphenate words at the end
+// of lines.
+const hyphens = "-¬=⸗\u2010\u00ad"
+
+// IsHyphenated returns true if the given (unnormalized) token ends
+// with a hyphen that follows a letter.
+func IsHyphenated(str string) bool {
+	rs := []rune(strings.TrimRightFunc(str, unicode.IsSpace))
+	if len(rs) < 2 {
+		return false
+	}
+	return strings.ContainsRune(hyphens, rs[len(rs)-1]) && unicode.IsLetter(rs[len(rs)-2])
+}
+
+// Dehyphenate removes the trailing hyphen (and any trailing
+// whitespace) from the given token.  If the token is not hyphenated,
+// it is returned unchanged.
+func Dehyphenate(str string) string {
+	if !IsHyphenated(str) {
+		return str
+	}
+	str = strings.TrimRightFunc(str, unicode.IsSpace)
+	_, size := utf8.DecodeLastRuneInString(str)
+	return str[:len(str)-size]
+}
+
+// JoinHyphenations returns a stream function that joins hyphenated
+// tokens at the end of lines with the first token of the next line.
+// The hyphen is removed from all OCR and GT tokens of the first token.
+// The original tokens are kept in the Parts of the joined token (see
+// SplitHyphenation).  This function must be used before Normalize.
+func JoinHyphenations() StreamFunc {
+	return func(ctx context.Context, in <-chan T, out chan<- T) error {
+		var last []T // hyphenated token (if any)
+		err := EachToken(ctx, in, func(t T) error {
+			if len(last) > 0 {
+				h := last[0]
+				last = last[:0]
+				if t.SOL && t.Document == h.Document && len(t.Tokens) == len(h.Tokens) {
+					t = joinHyphenation(h, t)
+				} else if err := SendTokens(ctx, out, h); err != nil {
+					return err
+				}
+			}
+			if t.EOL && IsHyphenated(t.Tokens[0]) {
+				last = append(last, t)
+				return nil
+			}
+			return SendTokens(ctx, out, t)
+		})
+		if err != nil {
+			return fmt.Errorf("join hyphenations: %v", err)
+		}
+		if err := SendTokens(ctx, out, last...); err != nil {
+			return fmt.Errorf("join hyphenations: %v", err)
+		}
+		return nil
+	}
+}
+
+func joinHyphenation(a, b T) T {
+	parts := a.Parts
+	if len(parts) == 0 {
+		parts = []T{a}
+	}
+	ret := a
+	ret.Parts = append(parts[:len(parts):len(parts)], b)
+	ret.Tokens = make([]string, len(a.Tokens))
+	for i := range a.Tokens {
+		ret.Tokens[i] = Dehyphenate(a.Tokens[i]) + b.Tokens[i]
+	}
+	chars := a.Chars
+	for len(chars) > 0 && unicode.IsSpace(chars[len(chars)-1].Char) {
+		chars = chars[:len(chars)-1]
+	}
+	if IsHyphenated(a.Tokens[0]) && len(chars) > 0 {
+		chars = chars[:len(chars)-1]
+	}
+	ret.Chars = append(append(Chars{}, chars...), b.Chars...)
+	ret.EOL = b.EOL
+	return ret
+}
+
+// SplitHyphenation splits the given correction of a joined hyphenated
+// token into the corrections of its parts.  The boundaries of the
+// parts are found by aligning the (normalized) master OCR token with
+// the correction.  Hyphens that remain in the normalized master OCR
+// tokens of the parts are appended to the according corrections.  If
+// the token is not joined, the correction is returned as is.
+func (t T) SplitHyphenation(cor string) []string {
+	if len(t.Parts) == 0 {
+		return []string{cor}
+	}
+	var m lev.Mat
+	ocr, sug := []rune(t.Tokens[0]), []rune(cor)
+	m.DistanceR(ocr, sug)
+	trace := m.TraceR(ocr, sug)
+	ret := make([]string, len(t.Parts))
+	var i, j, k, b, pos int
+	for p := 0; p < len(t.Parts)-1; p++ {
+		part := Dehyphenate(t.Parts[p].Tokens[0])
+		k += utf8.RuneCountInString(part)
+		for ; pos < len(trace) && i < k; pos++ {
+			if trace[pos] != '+' {
+				i++
+			}
+			if trace[pos] != '-' {
+				j++
+			}
+		}
+		ret[p] = string(sug[b:j]) + t.Parts[p].Tokens[0][len(part):]
+		b = j
+	}
+	ret[len(ret)-1] = string(sug[b:])
+	return ret
+}
@@ -0,0 +1,109 @@
+package apoco
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIsHyphenated(t *testing.T) {
+	for _, tc := range []struct {
+		test string
+		want bool
+	}{
+		{"", false},
+		{"-", false},
+		{"a", false},
+		{"a-", true},
+		{"Verfas-", true},
+		{"Verfas¬", true},
+		{"Verfas= ", true},
+		{"Verfas⸗", true},
+		{"1-", false},
+		{"a--", false},
+		{"a-b", false},
+	} {
+		t.Run(tc.test, func(t *testing.T) {
+			if got := IsHyphenated(tc.test); got != tc.want {
+				t.Fatalf("expected %t; got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestJoinHyphenations(t *testing.T) {
+	doc := &Document{}
+	mkline := func(strs ...string) []T {
+		ts := mktoks(strs...)
+		for i := range ts {
+			ts[i].Document = doc
+		}
+		ts[0].SOL = true
+		ts[len(ts)-1].EOL = true
+		return ts
+	}
+	for _, tc := range []struct {
+		test [][]T
+		want string
+	}{
+		{[][]T{mkline("a|a", "b|b")}, "a|a b|b"},
+		{[][]T{mkline("a|a", "Ver-|Ver¬"), mkline("fas-|fas", "sung|ung")}, "a|a Verfas-|Verfas sung|ung"},
+		{[][]T{mkline("a|a", "Ver-|Ver¬"), mkline("fas-|fas"), mkline("sung|sung")}, "a|a Verfassung|Verfassung"},
+		{[][]T{mkline("a|a", "Ver-|Ver¬")}, "a|a Ver-|Ver¬"},
+		{[][]T{mkline("a|a", "a-|b")}, "a|a a-|b"},
+	} {
+		var test []T
+		for _, line := range tc.test {
+			test = append(test, line...)
+		}
+		t.Run(fmttoks(test...), func(t *testing.T) {
+			var got []T
+			err := Pipe(context.Background(),
+				sendtoks(test...), JoinHyphenations(), readtoks(&got))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if str := fmttoks(got...); str != tc.want {
+				t.Fatalf("expected %s; got %s", tc.want, str)
+			}
+			for _, tok := range got {
+				if len(tok.Parts) == 0 {
+					continue
+				}
+				if tok.EOL != tok.Parts[len(tok.Parts)-1].EOL {
+					t.Errorf("bad end of line marker: %s", tok)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitHyphenation(t *testing.T) {
+	for _, tc := range []struct {
+		parts []string
+		cor   string
+		want  string
+	}{
+		{nil, "abc", "abc"},
+		{[]string{"ver", "fassung"}, "verfassung", "ver fassung"},
+		{[]string{"ver¬", "fassung"}, "verfassung", "ver¬ fassung"},
+		{[]string{"ver", "fafsung"}, "verfassung", "ver fassung"},
+		{[]string{"vcr", "fassnng"}, "verfassung", "ver fassung"},
+		{[]string{"ver", "fas", "sung"}, "verfassung", "ver fas sung"},
+		{[]string{"vr", "fassung"}, "verfassung", "ver fassung"},
+	} {
+		t.Run(tc.cor, func(t *testing.T) {
+			tok := T{Tokens: []string{tc.cor}}
+			if len(tc.parts) > 0 {
+				tok.Tokens[0] = strings.ReplaceAll(strings.Join(tc.parts, ""), "¬", "")
+			}
+			for _, p := range tc.parts {
+				tok.Parts = append(tok.Parts, T{Tokens: []string{p}})
+			}
+			got := strings.Join(tok.SplitHyphenation(tc.cor), " ")
+			if got != tc.want {
+				t.Fatalf("expected %s; got %s", tc.want, got)
+			}
+		})
+	}
+}
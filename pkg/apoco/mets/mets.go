@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"git.sr.ht/~flobar/apoco/pkg/apoco/node"
@@ -43,6 +44,23 @@ func (mets METS) AddAgent(pstep, agent string) error {
 	if mets.findAgent(pstep, agent) {
 		return nil
 	}
+	return mets.AppendAgent(pstep, agent)
+}
+
+// Note represents an OCR-D note of an agent.  The option is written as
+// the `ocrd:option` attribute of the note (e.g. `parameter`,
+// `input-file-grp`, `output-file-grp` or `page-id`).
+type Note struct {
+	Option, Value string
+}
+
+// OCRDNamespace defines the namespace of the OCR-D attributes.
+const OCRDNamespace = "https://ocr-d.de"
+
+// AppendAgent appends a new agent with the given notes to the metsHdr
+// of the mets tree.  Other than AddAgent, the agent is added even if
+// an agent with the same name already exists.
+func (mets METS) AppendAgent(pstep, agent string, notes ...Note) error {
 	// Get metsHdr node or create it if it does not exist, yet.
 	hdr := xmlquery.FindOne(mets.Root, "/*[local-name()='mets']/*[local-name()='metsHdr']")
 	if hdr == nil {
@@ -71,6 +89,27 @@ func (mets METS) AddAgent(pstep, agent string) error {
 	}
 	node.AppendChild(name, &xmlquery.Node{Type: xmlquery.TextNode, Data: agent})
 	node.AppendChild(agentnode, name)
+	if len(notes) > 0 {
+		if root := xmlquery.FindOne(mets.Root, "/*[local-name()='mets']"); root != nil {
+			if _, ok := node.LookupAttr(root, xml.Name{Space: "xmlns", Local: "ocrd"}); !ok {
+				node.SetAttr(root, xml.Attr{
+					Name:  xml.Name{Space: "xmlns", Local: "ocrd"},
+					Value: OCRDNamespace,
+				})
+			}
+		}
+	}
+	for _, note := range notes {
+		n := &xmlquery.Node{
+			Type:         xmlquery.ElementNode,
+			Data:         "note",
+			Prefix:       hdr.Prefix,
+			NamespaceURI: hdr.NamespaceURI,
+		}
+		node.SetAttr(n, xml.Attr{Name: xml.Name{Space: "ocrd", Local: "option"}, Value: note.Option})
+		node.AppendChild(n, &xmlquery.Node{Type: xmlquery.TextNode, Data: note.Value})
+		node.AppendChild(agentnode, n)
+	}
 	node.AppendChild(hdr, agentnode)
 	return nil
 }
@@ -109,14 +148,127 @@ func (mets METS) findAgent(pstep, agent string) bool {
 	return false
 }
 
-// FindFlocats returns the Flocat nodes for the given file group.
-func (mets METS) FindFlocats(fg string) []*xmlquery.Node {
-	expr := fmt.Sprintf("/*[local-name()='mets']/*[local-name()='fileSec']"+
-		"/*[local-name()='fileGrp'][@USE=%q]/*[local-name()='file']"+
-		"/*[local-name()='FLocat']", fg)
-	return xmlquery.Find(mets.Root, expr)
+// FindFlocats returns the Flocat nodes for the given file group.  If
+// any pages are given, only the Flocat nodes of files that belong to
+// one of the pages are returned.
+func (mets METS) FindFlocats(fg string, pages ...string) []*xmlquery.Node {
+	return mets.filterFlocats(findFileGrpFLocatFromRoot(mets.Root, fg), pages)
+}
+
+// PageIDs returns the ids of the pages in the physical structMap that
+// are selected by the given page id specification.  The
+// specification is a comma separated list of page ids or page id
+// ranges of the form `PHYS_0005..PHYS_0010` (both inclusive, in the
+// order of the physical structMap).  An empty specification selects
+// all pages and nil is returned.
+func (mets METS) PageIDs(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	all := mets.Pages()
+	pos := make(map[string]int, len(all))
+	for i, id := range all {
+		pos[id] = i
+	}
+	lookup := func(id string) (int, error) {
+		i, ok := pos[id]
+		if !ok {
+			return 0, fmt.Errorf("page ids %s: invalid page id: %s", mets.Name, id)
+		}
+		return i, nil
+	}
+	var ret []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ids := strings.SplitN(part, "..", 2)
+		b, err := lookup(strings.TrimSpace(ids[0]))
+		if err != nil {
+			return nil, err
+		}
+		e := b
+		if len(ids) == 2 {
+			if e, err = lookup(strings.TrimSpace(ids[1])); err != nil {
+				return nil, err
+			}
+		}
+		if e < b {
+			return nil, fmt.Errorf("page ids %s: invalid page range: %s", mets.Name, part)
+		}
+		ret = append(ret, all[b:e+1]...)
+	}
+	return ret, nil
+}
+
+// Pages returns the ids of all pages in the physical structMap in
+// their order.
+func (mets METS) Pages() []string {
+	var ret []string
+	for _, div := range xmlquery.Find(mets.Root, physicalPagesExpr) {
+		id, _ := node.LookupAttr(div, xml.Name{Local: "ID"})
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+// PageForFileID returns the id of the page in the physical structMap
+// that references the file with the given id.
+func (mets METS) PageForFileID(id string) (string, bool) {
+	expr := fmt.Sprintf("%s[./*[local-name()='fptr'][@FILEID=%q]]", physicalPagesExpr, id)
+	return node.LookupAttr(xmlquery.FindOne(mets.Root, expr), xml.Name{Local: "ID"})
+}
+
+// FileIDsForPages returns the set of the file ids that are referenced
+// by the given pages in the physical structMap.
+func (mets METS) FileIDsForPages(pages ...string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, page := range pages {
+		expr := fmt.Sprintf("%s[@ID=%q]/*[local-name()='fptr']", physicalPagesExpr, page)
+		for _, fptr := range xmlquery.Find(mets.Root, expr) {
+			id, _ := node.LookupAttr(fptr, xml.Name{Local: "FILEID"})
+			ret[id] = true
+		}
+	}
+	return ret
+}
+
+// ClearFileGrp removes the files of the given file group node.  If
+// any pages are given, only the files that belong to one of the pages
+// are removed.
+func (mets METS) ClearFileGrp(fg *xmlquery.Node, pages ...string) {
+	if len(pages) == 0 {
+		fg.FirstChild = nil
+		fg.LastChild = nil
+		return
+	}
+	ids := mets.FileIDsForPages(pages...)
+	for _, file := range xmlquery.Find(fg, "./*[local-name()='file']") {
+		if id, _ := node.LookupAttr(file, xml.Name{Local: "ID"}); ids[id] {
+			node.Delete(file)
+		}
+	}
 }
 
+func (mets METS) filterFlocats(flocats []*xmlquery.Node, pages []string) []*xmlquery.Node {
+	if len(pages) == 0 {
+		return flocats
+	}
+	ids := mets.FileIDsForPages(pages...)
+	var ret []*xmlquery.Node
+	for _, flocat := range flocats {
+		id, _ := node.LookupAttr(flocat.Parent, xml.Name{Local: "ID"})
+		if ids[id] {
+			ret = append(ret, flocat)
+		}
+	}
+	return ret
+}
+
+const physicalPagesExpr = "/*[local-name()='mets']/*[local-name()='structMap'][@TYPE='PHYSICAL']" +
+	"/*[local-name()='div']/*[local-name()='div'][@TYPE='page']"
+
 // FlocatGetPath returns the path of the flocat's link relative to the
 // given mets file's base directory unless the stored path is absolute.
 func (mets METS) FlocatGetPath(n *xmlquery.Node) string {
@@ -137,9 +289,11 @@ func (mets METS) FindFptr(id string) *xmlquery.Node {
 
 // FilePathsForFileGrp returns the list of file paths for the given
 // file group.  The returned file paths are updated to be relative to
-// the mets's file base directory if they are not absolute.
-func (mets METS) FilePathsForFileGrp(fg string) ([]string, error) {
-	nodes := findFileGrpFLocatFromRoot(mets.Root, fg)
+// the mets's file base directory if they are not absolute.  If any
+// pages are given, only the paths of files that belong to one of the
+// pages are returned.
+func (mets METS) FilePathsForFileGrp(fg string, pages ...string) ([]string, error) {
+	nodes := mets.FindFlocats(fg, pages...)
 	ret := make([]string, len(nodes))
 	for i, n := range nodes {
 		link, ok := node.LookupAttr(n, xml.Name{Space: "xlink", Local: "href"})
@@ -0,0 +1,65 @@
+package mets
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilePathsForFileGrp(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want []string
+		err  bool
+	}{
+		{"", []string{"0001", "0002", "0003", "0004"}, false},
+		{"PHYS_0002", []string{"0002"}, false},
+		{"PHYS_0001,PHYS_0004", []string{"0001", "0004"}, false},
+		{"PHYS_0002..PHYS_0004", []string{"0002", "0003", "0004"}, false},
+		{"PHYS_0001, PHYS_0003..PHYS_0004", []string{"0001", "0003", "0004"}, false},
+		{"PHYS_0003..PHYS_0001", nil, true},
+		{"PHYS_0005", nil, true},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			m, err := Open("testdata/mets.xml")
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			pages, err := m.PageIDs(tc.spec)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			paths, err := m.FilePathsForFileGrp("OCR-D-OCR", pages...)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			var got []string
+			for _, path := range paths {
+				base := filepath.Base(path)
+				got = append(got, base[:len(base)-len(filepath.Ext(base))])
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPageForFileID(t *testing.T) {
+	m, err := Open("testdata/mets.xml")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if got, ok := m.PageForFileID("OCR-D-OCR_0003"); !ok || got != "PHYS_0003" {
+		t.Errorf("expected PHYS_0003; got %s", got)
+	}
+	if got, ok := m.PageForFileID("OCR-D-OCR_0005"); ok {
+		t.Errorf("expected no page; got %s", got)
+	}
+}
@@ -0,0 +1,133 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// McNemar runs McNemar's test for paired nominal data.  The
+// discordant pairs are given by b and c (e.g. b instances flipped from
+// correct to wrong and c instances flipped from wrong to correct).  It
+// returns the test statistic (chi-squared with continuity correction)
+// and the two-sided p-value.  For less than 25 discordant pairs the
+// exact binomial p-value is used.
+func McNemar(b, c int) (float64, float64) {
+	n := b + c
+	if n == 0 {
+		return 0, 1
+	}
+	d := math.Abs(float64(b-c)) - 1
+	if d < 0 {
+		d = 0
+	}
+	chi2 := d * d / float64(n)
+	if n < 25 {
+		k := b
+		if c < k {
+			k = c
+		}
+		binom := distuv.Binomial{N: float64(n), P: .5}
+		return chi2, math.Min(1, 2*binom.CDF(float64(k)))
+	}
+	return chi2, 1 - distuv.ChiSquared{K: 1}.CDF(chi2)
+}
+
+// Bootstrap calculates the percentile bootstrap confidence interval
+// for the statistic f over n (paired) samples.  The statistic is
+// called with the indices of each of the given number of resamples.
+// The level gives the confidence level of the interval (e.g. 0.95).
+func Bootstrap(n, resamples int, level float64, r *rand.Rand, f func([]int) float64) (float64, float64) {
+	if n == 0 || resamples <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	stats := make([]float64, resamples)
+	idx := make([]int, n)
+	for i := range stats {
+		for j := range idx {
+			idx[j] = r.Intn(n)
+		}
+		stats[i] = f(idx)
+	}
+	sort.Float64s(stats)
+	alpha := (1 - level) / 2
+	return quantile(stats, alpha), quantile(stats, 1-alpha)
+}
+
+// quantile returns the p-quantile of the sorted values.
+func quantile(xs []float64, p float64) float64 {
+	i := int(math.Floor(p * float64(len(xs)-1)))
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(xs) {
+		i = len(xs) - 1
+	}
+	return xs[i]
+}
+
+// Confusion holds the confusion matrix of a binary classifier.
+type Confusion struct {
+	TP, FP, TN, FN int
+}
+
+// Evaluate predicts the values for x using the given predictor and
+// threshold and returns the confusion matrix of the predictions and
+// the gold values y.
+func Evaluate(p Predictor, x *mat.Dense, y *mat.VecDense, t float64) Confusion {
+	ps := ApplyThreshold(p.Predict(x), t)
+	var c Confusion
+	for i := 0; i < y.Len(); i++ {
+		c.Add(y.AtVec(i), ps.AtVec(i))
+	}
+	return c
+}
+
+// Add adds the prediction p for the gold value y.
+func (c *Confusion) Add(y, p float64) {
+	switch {
+	case y == True && p == True:
+		c.TP++
+	case y == True:
+		c.FN++
+	case p == True:
+		c.FP++
+	default:
+		c.TN++
+	}
+}
+
+// Precision returns the precision of the predictions.
+func (c Confusion) Precision() float64 {
+	return Ratio(c.TP, c.TP+c.FP)
+}
+
+// Recall returns the recall of the predictions.
+func (c Confusion) Recall() float64 {
+	return Ratio(c.TP, c.TP+c.FN)
+}
+
+// F1 returns the harmonic mean of the precision and the recall.
+func (c Confusion) F1() float64 {
+	p, r := c.Precision(), c.Recall()
+	if p == 0 && r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+// Accuracy returns the accuracy of the predictions.
+func (c Confusion) Accuracy() float64 {
+	return Ratio(c.TP+c.TN, c.TP+c.FP+c.TN+c.FN)
+}
+
+// Ratio returns a/b.  If b is 0, 0 is returned.
+func Ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
@@ -0,0 +1,91 @@
+package ml
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMcNemar(t *testing.T) {
+	for _, tc := range []struct {
+		b, c    int
+		chi2, p float64
+	}{
+		{0, 0, 0, 1},
+		{5, 5, 0, 1},
+		{10, 0, 8.1, 0.001953},
+		{0, 10, 8.1, 0.001953},
+		{30, 10, 9.025, 0.002663},
+	} {
+		t.Run(fmt.Sprintf("%d/%d", tc.b, tc.c), func(t *testing.T) {
+			chi2, p := McNemar(tc.b, tc.c)
+			if !eqf64(chi2, tc.chi2, 1e-6) || !eqf64(p, tc.p, 1e-6) {
+				t.Fatalf("expected %g/%g; got %g/%g", tc.chi2, tc.p, chi2, p)
+			}
+		})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	xs := make([]float64, 1000)
+	for i := range xs {
+		xs[i] = float64(i % 2)
+	}
+	mean := func(vals []float64) func([]int) float64 {
+		return func(idx []int) float64 {
+			var sum float64
+			for _, i := range idx {
+				sum += vals[i]
+			}
+			return sum / float64(len(idx))
+		}
+	}
+	r := rand.New(rand.NewSource(42))
+	lo, hi := Bootstrap(len(xs), 500, .95, r, mean(xs))
+	if !(lo < .5 && .5 < hi) || hi-lo > .1 {
+		t.Errorf("bad confidence interval: [%g, %g]", lo, hi)
+	}
+	ones := make([]float64, 100)
+	for i := range ones {
+		ones[i] = 1
+	}
+	lo, hi = Bootstrap(len(ones), 100, .95, r, mean(ones))
+	if lo != 1 || hi != 1 {
+		t.Errorf("bad confidence interval: [%g, %g]", lo, hi)
+	}
+}
+
+type predictor []float64
+
+func (p predictor) Predict(*mat.Dense) *mat.VecDense {
+	return mat.NewVecDense(len(p), append([]float64(nil), p...))
+}
+
+func TestEvaluate(t *testing.T) {
+	y := mat.NewVecDense(6, []float64{1, 1, 1, 0, 0, 0})
+	p := predictor{.9, .8, .2, .7, .1, .3}
+	c := Evaluate(p, mat.NewDense(6, 1, nil), y, .5)
+	if want := (Confusion{TP: 2, FN: 1, FP: 1, TN: 2}); c != want {
+		t.Fatalf("expected %v; got %v", want, c)
+	}
+	for _, tc := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"precision", c.Precision(), 2.0 / 3.0},
+		{"recall", c.Recall(), 2.0 / 3.0},
+		{"f1", c.F1(), 2.0 / 3.0},
+		{"accuracy", c.Accuracy(), 4.0 / 6.0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if !eqf64(tc.got, tc.want, 1e-9) {
+				t.Fatalf("expected %g; got %g", tc.want, tc.got)
+			}
+		})
+	}
+	if f1 := (Confusion{}).F1(); f1 != 0 {
+		t.Fatalf("expected 0; got %g", f1)
+	}
+}
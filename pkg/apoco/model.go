@@ -4,11 +4,13 @@ import (
 	"bufio"
 	"compress/gzip"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
 )
@@ -30,11 +32,44 @@ type LMConfig struct {
 
 // ModelData holds a linear regression model.
 type ModelData struct {
-	Features []string // Feature names used to train the model.
-	Model    *ml.LR   // The trained model.
+	Features []string  `json:"features"` // Feature names used to train the model.
+	Model    *ml.LR    `json:"model"`    // The trained model.
+	Meta     ModelMeta `json:"meta"`     // Training metadata of the model.
 }
 
-// ReadModel reads a model from a gob compressed input file.  If the
+// ModelMeta holds metadata about the training of a model.  Models
+// that were trained before the metadata was introduced contain an
+// empty metadata record.
+type ModelMeta struct {
+	Version  string         `json:"version"`  // Version of apoco that trained the model.
+	Config   string         `json:"config"`   // JSON encoded configuration of the training.
+	Files    []TrainingFile `json:"files"`    // Training files.
+	Started  time.Time      `json:"started"`  // Start time of the training.
+	Finished time.Time      `json:"finished"` // End time of the training.
+}
+
+// TrainingFile identifies a training file and its content.
+type TrainingFile struct {
+	Path   string `json:"path"`   // Path of the training file.
+	SHA256 string `json:"sha256"` // Hex encoded sha256 sum of the file's content.
+}
+
+// ModelSchemaVersion defines the current version of the json model
+// format.  It is incremented for any incompatible change of the
+// format.
+const ModelSchemaVersion = 1
+
+// jsonModel is the container of the json model format.
+type jsonModel struct {
+	Schema             int                          `json:"schema"`
+	Models             map[string]map[int]ModelData `json:"models"`
+	GlobalHistPatterns map[string]float64           `json:"globalHistPatterns,omitempty"`
+	GlobalOCRPatterns  map[string]float64           `json:"globalOCRPatterns,omitempty"`
+	LM                 map[string]*FreqList         `json:"lm,omitempty"`
+}
+
+// ReadModel reads a model from a gob encoded or a gzipped json input
+// file.  The format of the file is detected automatically.  If the
 // given file does not exist, the according language models are loaded
 // and a new model is returned.  If create is set to false no new
 // model will be created and the model must be read from an existing
@@ -60,32 +95,124 @@ func ReadModel(name string, lms map[string]LMConfig, create bool) (*Model, error
 		return fail(err)
 	}
 	defer r.Close()
-	var model Model
-	if err := gob.NewDecoder(r).Decode(&model); err != nil {
+	model, err := readModel(bufio.NewReader(r))
+	if err != nil {
 		return fail(err)
 	}
 	Log("read model from %s", name)
+	return model, nil
+}
+
+// readModel detects the format of the model and decodes it.  Gzipped
+// input is assumed to be json encoded.
+func readModel(r *bufio.Reader) (*Model, error) {
+	magic, err := r.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b { // gzip header
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return readJSONModel(gz)
+	}
+	if magic[0] == '{' {
+		return readJSONModel(r)
+	}
+	var model Model
+	if err := gob.NewDecoder(r).Decode(&model); err != nil {
+		return nil, err
+	}
 	return &model, nil
 }
 
-// Write writes the model as gob encoded, gziped file to the given
+func readJSONModel(r io.Reader) (*Model, error) {
+	var data jsonModel
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Schema < 1 || data.Schema > ModelSchemaVersion {
+		return nil, fmt.Errorf("unsupported model schema version: %d", data.Schema)
+	}
+	if data.Models == nil {
+		data.Models = make(map[string]map[int]ModelData)
+	}
+	return &Model{
+		Models:             data.Models,
+		GlobalHistPatterns: data.GlobalHistPatterns,
+		GlobalOCRPatterns:  data.GlobalOCRPatterns,
+		LM:                 data.LM,
+	}, nil
+}
+
+// Write writes the model to the given path overwriting any previous
+// existing models.  If the path ends with `.json` or `.json.gz`, the
+// model is written in the (gzipped) json format.  Otherwise the model
+// is written gob encoded.
+func (m *Model) Write(name string) error {
+	switch {
+	case strings.HasSuffix(name, ".json.gz"):
+		return m.write(name, m.writeGzippedJSON)
+	case strings.HasSuffix(name, ".json"):
+		return m.write(name, m.writeJSON)
+	default:
+		return m.write(name, m.writeGob)
+	}
+}
+
+// WriteGob writes the model gob encoded to the given path
+// overwriting any previous existing models.
+func (m *Model) WriteGob(name string) error {
+	return m.write(name, m.writeGob)
+}
+
+// WriteJSON writes the model in the gzipped json format to the given
 // path overwriting any previous existing models.
-func (m *Model) Write(name string) (err error) {
+func (m *Model) WriteJSON(name string) error {
+	return m.write(name, m.writeGzippedJSON)
+}
+
+func (m *Model) write(name string, f func(io.Writer) error) (err error) {
 	w, err := os.Create(name)
 	if err != nil {
 		return fmt.Errorf("write %s: %v", name, err)
 	}
 	defer func() {
 		if exx := w.Close(); exx != nil && err == nil {
-			err = fmt.Errorf("write %s: %v", name, err)
+			err = fmt.Errorf("write %s: %v", name, exx)
 		}
 	}()
-	if err := gob.NewEncoder(w).Encode(m); err != nil {
+	if err := f(w); err != nil {
 		return fmt.Errorf("write %s: %v", name, err)
 	}
 	return nil
 }
 
+func (m *Model) writeGob(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(m)
+}
+
+func (m *Model) writeGzippedJSON(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if err := m.writeJSON(gz); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+func (m *Model) writeJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jsonModel{
+		Schema:             ModelSchemaVersion,
+		Models:             m.Models,
+		GlobalHistPatterns: m.GlobalHistPatterns,
+		GlobalOCRPatterns:  m.GlobalOCRPatterns,
+		LM:                 m.LM,
+	})
+}
+
 // Put inserts the weights and the according feature set for the given
 // configuration into this model.
 func (m *Model) Put(mod string, nocr int, lr *ml.LR, fs []string) {
@@ -98,6 +225,18 @@ func (m *Model) Put(mod string, nocr int, lr *ml.LR, fs []string) {
 	}
 }
 
+// SetMeta sets the training metadata for the given configuration.
+// The according model must exist.
+func (m *Model) SetMeta(mod string, nocr int, meta ModelMeta) error {
+	data, ok := m.Models[mod][nocr]
+	if !ok {
+		return fmt.Errorf("set meta %s/%d: cannot find", mod, nocr)
+	}
+	data.Meta = meta
+	m.Models[mod][nocr] = data
+	return nil
+}
+
 // Get loads the the model and the according feature set for the given
 // configuration.
 func (m *Model) Get(mod string, nocr int) (*ml.LR, FeatureSet, error) {
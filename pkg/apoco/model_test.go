@@ -0,0 +1,74 @@
+package apoco
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestModelFormats(t *testing.T) {
+	orig, err := ReadModel("../../testdata/model.bin", nil, false)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	lr, _, err := orig.Get("rr", 2)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	meta := ModelMeta{
+		Version:  "test",
+		Files:    []TrainingFile{{Path: "rr.csv", SHA256: "abc"}},
+		Started:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Finished: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	if err := orig.SetMeta("rr", 2, meta); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name  string
+		write func(*Model, string) error
+	}{
+		{"model.bin", (*Model).Write},
+		{"model.json", (*Model).Write},
+		{"model.json.gz", (*Model).Write},
+		{"model.gob", (*Model).WriteGob},
+		{"model.gz", (*Model).WriteJSON},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			if err := tc.write(orig, path); err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			got, err := ReadModel(path, nil, false)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			glr, _, err := got.Get("rr", 2)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if !reflect.DeepEqual(glr.Weights(), lr.Weights()) {
+				t.Fatalf("expected %v; got %v", lr.Weights(), glr.Weights())
+			}
+			if gmeta := got.Models["rr"][2].Meta; !reflect.DeepEqual(gmeta, meta) {
+				t.Fatalf("expected %v; got %v", meta, gmeta)
+			}
+			if len(got.Models) != len(orig.Models) {
+				t.Fatalf("expected %d models; got %d", len(orig.Models), len(got.Models))
+			}
+		})
+	}
+}
+
+func TestModelBadSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(`{"schema":999}`), 0666); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := ReadModel(path, nil, false); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
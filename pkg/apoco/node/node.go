@@ -111,6 +111,24 @@ func PrependSibling(n, s *xmlquery.Node) {
 	n.PrevSibling = s
 }
 
+// AppendSibling appends to the node n a new sibling s. Both given
+// nodes must not be null.
+func AppendSibling(n, s *xmlquery.Node) {
+	if n.NextSibling == nil {
+		n.Parent.LastChild = s
+		s.Parent = n.Parent
+		s.PrevSibling = n
+		s.NextSibling = nil
+		n.NextSibling = s
+		return
+	}
+	n.NextSibling.PrevSibling = s
+	s.Parent = n.Parent
+	s.PrevSibling = n
+	s.NextSibling = n.NextSibling
+	n.NextSibling = s
+}
+
 // Delete removes the given node from its tree.  The given node must
 // not be nil.
 func Delete(n *xmlquery.Node) {
@@ -122,11 +140,15 @@ func Delete(n *xmlquery.Node) {
 	}
 	if n.PrevSibling == nil {
 		n.Parent.FirstChild = n.NextSibling
+		n.NextSibling.PrevSibling = nil
+		n.NextSibling = nil
 		n.Parent = nil
 		return
 	}
 	if n.NextSibling == nil {
 		n.Parent.LastChild = n.PrevSibling
+		n.PrevSibling.NextSibling = nil
+		n.PrevSibling = nil
 		n.Parent = nil
 		return
 	}
@@ -23,3 +23,57 @@ func TestPrettyPrint(t *testing.T) {
 		t.Errorf("expected %s; got %s", xml, got)
 	}
 }
+
+func TestDelete(t *testing.T) {
+	for _, tc := range []struct {
+		del, want string
+	}{
+		{"a", "<r><b></b><c></c></r>"},
+		{"b", "<r><a></a><c></c></r>"},
+		{"c", "<r><a></a><b></b></r>"},
+	} {
+		t.Run(tc.del, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader("<r><a/><b/><c/></r>"))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			Delete(xmlquery.FindOne(doc, "//"+tc.del))
+			if got := xmlquery.FindOne(doc, "/r").OutputXML(true); got != tc.want {
+				t.Errorf("expected %s; got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAppendSibling(t *testing.T) {
+	for _, tc := range []struct {
+		after, want, last string
+	}{
+		{"a", "<r><a></a><x></x><b></b><c></c></r>", "c"},
+		{"b", "<r><a></a><b></b><x></x><c></c></r>", "c"},
+		{"c", "<r><a></a><b></b><c></c><x></x></r>", "x"},
+	} {
+		t.Run(tc.after, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader("<r><a/><b/><c/></r>"))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			n := xmlquery.FindOne(doc, "//"+tc.after)
+			s := &xmlquery.Node{Type: xmlquery.ElementNode, Data: "x"}
+			AppendSibling(n, s)
+			r := xmlquery.FindOne(doc, "/r")
+			if got := r.OutputXML(true); got != tc.want {
+				t.Errorf("expected %s; got %s", tc.want, got)
+			}
+			if got := r.LastChild.Data; got != tc.last {
+				t.Errorf("expected last child %s; got %s", tc.last, got)
+			}
+			if s.Parent != r || s.PrevSibling != n || n.NextSibling != s {
+				t.Errorf("invalid links of appended sibling")
+			}
+			if s.NextSibling != nil && s.NextSibling.PrevSibling != s {
+				t.Errorf("invalid back link of next sibling")
+			}
+		})
+	}
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/align"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/mets"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/node"
+	"git.sr.ht/~flobar/lev"
 	"github.com/antchfx/xmlquery"
 )
 
@@ -20,24 +24,44 @@ import (
 const MIMEType = "application/vnd.prima.page+xml"
 
 // Tokenize returns a function that reads tokens from the page xml
-// files of the given file groups.  An empty token is inserted as
-// sentry between the token of different file groups.  The returned
-// function ignores the input stream it just writes tokens to the
-// output stream.
-func Tokenize(metsName string, fgs ...string) apoco.StreamFunc {
+// files of the given file groups.  Only the files of the pages
+// selected by the given page id specification are read (see
+// mets.PageIDs).  An empty token is inserted as sentry between the
+// token of different file groups.  The returned function ignores the
+// input stream it just writes tokens to the output stream.
+func Tokenize(metsName, pages string, fgs ...string) apoco.StreamFunc {
+	return tokenize(metsName, pages, tokenizePageXML, fgs...)
+}
+
+// TokenizeLines returns a function that reads tokens from the
+// TextLine elements of the page xml files of the given file groups
+// and pages (see Tokenize).  Word elements are ignored.  The primary
+// text of each line is split into words and the parallel TextEquivs
+// of the line are aligned to these words using the given alignment
+// mode.  The returned function ignores the input stream it just
+// writes tokens to the output stream.
+func TokenizeLines(metsName, pages string, mode align.Mode, fgs ...string) apoco.StreamFunc {
+	return tokenize(metsName, pages, lineTokenizer(mode), fgs...)
+}
+
+func tokenize(metsName, pages string, fn tokenizeFunc, fgs ...string) apoco.StreamFunc {
 	return func(ctx context.Context, _ <-chan apoco.T, out chan<- apoco.T) error {
 		m, err := mets.Open(metsName)
 		if err != nil {
 			return fmt.Errorf("tokenize: %v", err)
 		}
+		ids, err := m.PageIDs(pages)
+		if err != nil {
+			return fmt.Errorf("tokenize: %v", err)
+		}
 		for _, fg := range fgs {
-			files, err := m.FilePathsForFileGrp(fg)
+			files, err := m.FilePathsForFileGrp(fg, ids...)
 			if err != nil {
 				return fmt.Errorf("tokenize: %v", err)
 			}
 			doc := &apoco.Document{Group: fg}
 			for _, file := range files {
-				err := tokenizePageXML(ctx, file, doc, out)
+				err := fn(ctx, file, doc, out)
 				if err != nil {
 					return err
 				}
@@ -52,6 +76,18 @@ func Tokenize(metsName string, fgs ...string) apoco.StreamFunc {
 // function ignores the input stream.  It only writes tokens to the
 // output stream.
 func TokenizeDirs(ext string, dirs ...string) apoco.StreamFunc {
+	return tokenizeDirs(ext, tokenizePageXML, dirs...)
+}
+
+// TokenizeLinesDirs returns a function that reads the lines of page
+// xml files with a matching file extension from the given
+// directories (see TokenizeLines).  The returned function ignores the
+// input stream.  It only writes tokens to the output stream.
+func TokenizeLinesDirs(ext string, mode align.Mode, dirs ...string) apoco.StreamFunc {
+	return tokenizeDirs(ext, lineTokenizer(mode), dirs...)
+}
+
+func tokenizeDirs(ext string, fn tokenizeFunc, dirs ...string) apoco.StreamFunc {
 	return func(ctx context.Context, _ <-chan apoco.T, out chan<- apoco.T) error {
 		for _, dir := range dirs {
 			files, err := gatherFilesInDir(dir, ext)
@@ -60,7 +96,7 @@ func TokenizeDirs(ext string, dirs ...string) apoco.StreamFunc {
 			}
 			doc := &apoco.Document{Group: dir}
 			for _, file := range files {
-				if err := tokenizePageXML(ctx, file, doc, out); err != nil {
+				if err := fn(ctx, file, doc, out); err != nil {
 					return err
 				}
 			}
@@ -69,6 +105,10 @@ func TokenizeDirs(ext string, dirs ...string) apoco.StreamFunc {
 	}
 }
 
+// tokenizeFunc reads the tokens of a page xml file and writes them
+// to the output channel.
+type tokenizeFunc func(context.Context, string, *apoco.Document, chan<- apoco.T) error
+
 func gatherFilesInDir(dir, ext string) ([]string, error) {
 	var files []string
 	err := filepath.Walk(dir, func(p string, i os.FileInfo, err error) error {
@@ -131,10 +171,7 @@ func newTokenFromNode(file string, doc *apoco.Document, wordNode *xmlquery.Node)
 	if !ok {
 		return apoco.T{}, fmt.Errorf("newTokenFromNode: missing id for word node")
 	}
-	base := filepath.Base(file)
-	base = base[0 : len(base)-len(filepath.Ext(base))]
-	id = base + "_" + id
-	ret := apoco.T{Document: doc, File: file, ID: id}
+	ret := apoco.T{Document: doc, File: file, ID: TokenID(file, id)}
 	lines := FindUnicodesInRegionSorted(node.Parent(wordNode))
 	words := FindUnicodesInRegionSorted(wordNode)
 	for i := 0; i < len(lines) && i < len(words); i++ {
@@ -166,6 +203,361 @@ func readCharsFromNode(wordNode *xmlquery.Node) ([]apoco.Char, error) {
 	return ret, nil
 }
 
+func lineTokenizer(mode align.Mode) tokenizeFunc {
+	return func(ctx context.Context, file string, doc *apoco.Document, out chan<- apoco.T) error {
+		var mat lev.Mat
+		is, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("tokenizePageXMLLines %s: %v", file, err)
+		}
+		defer is.Close()
+		xml, err := xmlquery.Parse(is)
+		if err != nil {
+			return fmt.Errorf("tokenizePageXMLLines %s: %v", file, err)
+		}
+		lines, err := xmlquery.QueryAll(xml, "//*[local-name()='TextLine']")
+		if err != nil {
+			return fmt.Errorf("tokenizePageXMLLines %s: %v", file, err)
+		}
+		for _, line := range lines {
+			ts, err := newTokensFromLine(mode, &mat, file, doc, line)
+			if err != nil {
+				return fmt.Errorf("tokenizePageXMLLines %s: %v", file, err)
+			}
+			if err := apoco.SendTokens(ctx, out, ts...); err != nil {
+				return fmt.Errorf("tokenizePageXMLLines %s: %v", file, err)
+			}
+		}
+		return nil
+	}
+}
+
+func newTokensFromLine(mode align.Mode, mat *lev.Mat, file string, doc *apoco.Document, lineNode *xmlquery.Node) ([]apoco.T, error) {
+	id, ok := node.LookupAttr(lineNode, xml.Name{Local: "id"})
+	if !ok {
+		return nil, fmt.Errorf("newTokensFromLine: missing id for line node")
+	}
+	unicodes := FindUnicodesInRegionSorted(lineNode)
+	if len(unicodes) == 0 {
+		return nil, nil
+	}
+	texts := make([][]rune, len(unicodes))
+	for i := range unicodes {
+		texts[i] = []rune(node.Data(node.FirstChild(unicodes[i])))
+	}
+	// Lines do not contain any glyphs.  Use the line's confidence
+	// for all of its characters.
+	conf, _ := node.LookupAttrAsFloat(unicodes[0].Parent, xml.Name{Local: "conf"})
+	alignments := mode.Align(mat, texts[0], texts[1:]...)
+	ts := make([]apoco.T, len(alignments))
+	for i := range alignments {
+		ts[i] = apoco.T{Document: doc, File: file, ID: LineTokenID(file, id, i+1)}
+		for j, p := range alignments[i] {
+			if j == 0 {
+				for _, r := range p.Slice() {
+					ts[i].Chars = append(ts[i].Chars, apoco.Char{Char: r, Conf: conf})
+				}
+			}
+			ts[i].Tokens = append(ts[i].Tokens, p.String())
+		}
+	}
+	// Remove empty tokens from the start and the end of the line and
+	// mark the first and last tokens on the line.
+	for len(ts) > 0 && ts[len(ts)-1].Tokens[0] == "" {
+		ts = ts[:len(ts)-1]
+	}
+	for len(ts) > 0 && ts[0].Tokens[0] == "" {
+		ts = ts[1:]
+	}
+	if len(ts) > 0 {
+		ts[0].SOL = true
+		ts[len(ts)-1].EOL = true
+	}
+	return ts, nil
+}
+
+// LineWords splits the given primary text of a line into its words.
+// The i-th word of the line corresponds to the token with the id
+// LineTokenID(file, id, i+1) of the line tokenizer.
+func LineWords(text string) []string {
+	alignments := align.Do([]rune(text))
+	ret := make([]string, len(alignments))
+	for i := range alignments {
+		ret[i] = alignments[i][0].String()
+	}
+	return ret
+}
+
+// TokenID returns the id of the token for the element with the given
+// id in the given file.
+func TokenID(file, id string) string {
+	base := filepath.Base(file)
+	base = base[0 : len(base)-len(filepath.Ext(base))]
+	return base + "_" + id
+}
+
+// LineTokenID returns the id of the i-th token (counting from 1) in
+// the line with the given id in the given file.
+func LineTokenID(file, id string, i int) string {
+	return TokenID(file, id) + ":" + strconv.Itoa(i)
+}
+
+// UpdateGlyphs aligns the given (corrected) text of a word with the
+// primary text of the word's glyphs and updates the glyphs
+// accordingly.  Glyphs with changed characters get a new primary
+// TextEquiv with the given attributes, glyphs whose characters were
+// deleted are removed and inserted characters are added as new
+// glyphs.  The bounding box of the neighbouring glyph is split
+// between the neighbour and its inserted glyphs.  The original
+// TextEquivs of changed glyphs are handed to the given keep function
+// (as Unicode nodes sorted by their index), which has to remove or
+// re-index them.  If keep is nil, they are removed.  Words without
+// glyphs are not changed.
+func UpdateGlyphs(word *xmlquery.Node, text string, keep func([]*xmlquery.Node), attrs ...xml.Attr) {
+	glyphs := xmlquery.Find(word, "./*[local-name()='Glyph']")
+	if len(glyphs) == 0 {
+		return
+	}
+	// Gather the primary text of the glyphs and remember the glyph
+	// for each of its characters.
+	var ocr []rune
+	var gidx []int
+	for i, glyph := range glyphs {
+		unicodes := FindUnicodesInRegionSorted(glyph)
+		if len(unicodes) == 0 {
+			continue
+		}
+		for _, r := range node.Data(node.FirstChild(unicodes[0])) {
+			ocr = append(ocr, r)
+			gidx = append(gidx, i)
+		}
+	}
+	cor := []rune(text)
+	if string(ocr) == text {
+		return
+	}
+	var mat lev.Mat
+	mat.DistanceR(ocr, cor)
+	texts := make([][]rune, len(glyphs))
+	changed := make([]bool, len(glyphs))
+	inserts := make(map[int][]rune) // -1 denotes insertions before the first glyph
+	last := -1
+	for i, j, trace := 0, 0, mat.TraceR(ocr, cor); len(trace) > 0; trace = trace[1:] {
+		switch trace[0] {
+		case '|', '#':
+			last = gidx[i]
+			texts[last] = append(texts[last], cor[j])
+			changed[last] = changed[last] || trace[0] == '#'
+			i++
+			j++
+		case '-':
+			last = gidx[i]
+			changed[last] = true
+			i++
+		case '+':
+			inserts[last] = append(inserts[last], cor[j])
+			j++
+		}
+	}
+	// Insert new glyphs.  The ids of the new glyphs must not collide
+	// with any existing id of the document (e.g. glyphs inserted by a
+	// previous correction).
+	var n int
+	var ids map[string]bool
+	newGlyph := func(neighbour *xmlquery.Node, r rune) *xmlquery.Node {
+		if ids == nil {
+			root := word
+			for root.Parent != nil {
+				root = root.Parent
+			}
+			ids = make(map[string]bool)
+			for _, e := range xmlquery.Find(root, "//*[@id]") {
+				ids[e.SelectAttr("id")] = true
+			}
+		}
+		id, _ := node.LookupAttr(word, xml.Name{Local: "id"})
+		var gid string
+		for gid == "" || ids[gid] {
+			n++
+			gid = id + "_glyph" + strconv.Itoa(n)
+		}
+		ids[gid] = true
+		glyph := newNode(neighbour, "Glyph")
+		node.SetAttr(glyph, xml.Attr{
+			Name:  xml.Name{Local: "id"},
+			Value: gid,
+		})
+		node.AppendChild(glyph, newTextEquiv(glyph, string(r), attrs...))
+		return glyph
+	}
+	for i := -1; i < len(glyphs); i++ {
+		rs := inserts[i]
+		if len(rs) == 0 {
+			continue
+		}
+		if i == -1 {
+			news := make([]*xmlquery.Node, len(rs))
+			for j, r := range rs {
+				news[j] = newGlyph(glyphs[0], r)
+				node.PrependSibling(glyphs[0], news[j])
+			}
+			splitGlyph(glyphs[0], len(texts[0]), news, true)
+			continue
+		}
+		prev := glyphs[i]
+		news := make([]*xmlquery.Node, len(rs))
+		for j, r := range rs {
+			news[j] = newGlyph(glyphs[i], r)
+			node.AppendSibling(prev, news[j])
+			prev = news[j]
+		}
+		splitGlyph(glyphs[i], len(texts[i]), news, false)
+	}
+	// Update or delete changed glyphs.
+	for i, glyph := range glyphs {
+		if !changed[i] {
+			continue
+		}
+		if len(texts[i]) == 0 {
+			node.Delete(glyph)
+			continue
+		}
+		unicodes := FindUnicodesInRegionSorted(glyph)
+		tes := xmlquery.Find(glyph, "./*[local-name()='TextEquiv']")
+		te := newTextEquiv(glyph, string(texts[i]), attrs...)
+		if len(tes) == 0 {
+			node.AppendChild(glyph, te)
+			continue
+		}
+		node.PrependSibling(tes[0], te)
+		if keep != nil {
+			keep(unicodes)
+			continue
+		}
+		for _, te := range tes {
+			node.Delete(te)
+		}
+	}
+}
+
+// splitGlyph splits the bounding box of the given glyph with n
+// characters between the glyph and the given new glyphs (one
+// character each) that are inserted before or after the glyph.  If
+// the glyph does not have any coordinates, nothing is done.
+func splitGlyph(glyph *xmlquery.Node, n int, news []*xmlquery.Node, before bool) {
+	box, ok := BoundingBox(glyph)
+	if !ok {
+		return
+	}
+	weights := make([]int, len(news)+1)
+	for i := range weights {
+		weights[i] = 1
+	}
+	pos := 0 // Position of the glyph's part of the box.
+	if before {
+		pos = len(news)
+	}
+	if n > 1 {
+		weights[pos] = n
+	}
+	boxes := SplitBoundingBox(box, weights...)
+	SetCoords(glyph, boxes[pos])
+	boxes = append(boxes[:pos], boxes[pos+1:]...)
+	for i := range news {
+		SetCoords(news[i], boxes[i])
+	}
+}
+
+func newTextEquiv(p *xmlquery.Node, text string, attrs ...xml.Attr) *xmlquery.Node {
+	te := newNode(p, "TextEquiv")
+	node.SetAttr(te, xml.Attr{Name: xml.Name{Local: "index"}, Value: "1"})
+	for _, attr := range attrs {
+		node.SetAttr(te, attr)
+	}
+	unicode := newNode(p, "Unicode")
+	node.AppendChild(unicode, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+	node.AppendChild(te, unicode)
+	return te
+}
+
+func newNode(p *xmlquery.Node, data string) *xmlquery.Node {
+	return &xmlquery.Node{
+		Type:         xmlquery.ElementNode,
+		Data:         data,
+		Prefix:       p.Prefix,
+		NamespaceURI: p.NamespaceURI,
+	}
+}
+
+// BoundingBox returns the bounding box of the Coords polygon of the
+// given region (TextRegion, TextLine, Word or Glyph).  It returns
+// false if the region has no or invalid coordinates.
+func BoundingBox(region *xmlquery.Node) (image.Rectangle, bool) {
+	coords := xmlquery.FindOne(region, "./*[local-name()='Coords']")
+	points, ok := node.LookupAttr(coords, xml.Name{Local: "points"})
+	if !ok {
+		return image.Rectangle{}, false
+	}
+	var ret image.Rectangle
+	var n int
+	for _, point := range strings.Fields(points) {
+		var p image.Point
+		if _, err := fmt.Sscanf(point, "%d,%d", &p.X, &p.Y); err != nil {
+			return image.Rectangle{}, false
+		}
+		if n == 0 {
+			ret = image.Rectangle{Min: p, Max: p}
+		}
+		ret = ret.Union(image.Rectangle{Min: p, Max: p.Add(image.Point{X: 1, Y: 1})})
+		n++
+	}
+	return ret, n > 0
+}
+
+// SplitBoundingBox splits the given bounding box horizontally into
+// consecutive parts whose widths are proportional to the given
+// weights (e.g. the number of characters).  Each part is at least one
+// pixel wide.  If all weights are zero, the parts have equal widths.
+func SplitBoundingBox(r image.Rectangle, weights ...int) []image.Rectangle {
+	var total int
+	for _, w := range weights {
+		total += w
+	}
+	equal := total == 0
+	if equal {
+		total = len(weights)
+	}
+	ret := make([]image.Rectangle, len(weights))
+	var sum int
+	for i, w := range weights {
+		if equal {
+			w = 1
+		}
+		min := r.Min.X + r.Dx()*sum/total
+		sum += w
+		max := r.Min.X + r.Dx()*sum/total
+		if max <= min {
+			max = min + 1
+		}
+		ret[i] = image.Rect(min, r.Min.Y, max, r.Max.Y)
+	}
+	return ret
+}
+
+// SetCoords sets the Coords polygon of the given region to the given
+// bounding box (see BoundingBox).  If the region does not have any
+// Coords, a new Coords node is inserted as its first child.
+func SetCoords(region *xmlquery.Node, r image.Rectangle) {
+	points := fmt.Sprintf("%d,%d %d,%d %d,%d %d,%d",
+		r.Min.X, r.Min.Y, r.Max.X-1, r.Min.Y, r.Max.X-1, r.Max.Y-1, r.Min.X, r.Max.Y-1)
+	coords := xmlquery.FindOne(region, "./*[local-name()='Coords']")
+	if coords == nil {
+		coords = newNode(region, "Coords")
+		node.PrependChild(region, coords)
+	}
+	node.SetAttr(coords, xml.Attr{Name: xml.Name{Local: "points"}, Value: points})
+}
+
 // FindUnicodesInRegionSorted searches for the TextEquiv / Unicode
 // nodes beneath a text region (TextRegion, Line, Word, Glyph).  The
 // returend node list is ordered by the TextEquiv's index entries
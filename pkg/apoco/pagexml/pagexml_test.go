@@ -0,0 +1,247 @@
+package pagexml
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/align"
+	"git.sr.ht/~flobar/apoco/pkg/apoco/node"
+	"github.com/antchfx/xmlquery"
+)
+
+func TestTokenizeLinesDirs(t *testing.T) {
+	for _, mode := range []align.Mode{align.ModeDo, align.ModeLev, align.ModeMSA} {
+		var got []apoco.T
+		err := apoco.Pipe(
+			context.Background(),
+			TokenizeLinesDirs(".xml", mode, "testdata/lines"),
+			func(ctx context.Context, in <-chan apoco.T, _ chan<- apoco.T) error {
+				return apoco.EachToken(ctx, in, func(t apoco.T) error {
+					got = append(got, t)
+					return nil
+				})
+			},
+		)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		want := []struct {
+			id       string
+			tokens   []string
+			sol, eol bool
+		}{
+			{"0001_l1:1", []string{"Die", "Die", "Die"}, true, false},
+			{"0001_l1:2", []string{"Verfaſſung,", "Verfassung", "Verfaſſung,"}, false, false},
+			{"0001_l1:3", []string{"des", "des", "des"}, false, true},
+			{"0001_l2:1", []string{"Landes", "Lan des", "Landes"}, true, true},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d tokens; got %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i].ID != want[i].id {
+				t.Errorf("expected id %s; got %s", want[i].id, got[i].ID)
+			}
+			if !reflect.DeepEqual(got[i].Tokens, want[i].tokens) {
+				t.Errorf("expected tokens %v; got %v", want[i].tokens, got[i].Tokens)
+			}
+			if got[i].SOL != want[i].sol || got[i].EOL != want[i].eol {
+				t.Errorf("bad sol/eol for %s", got[i].ID)
+			}
+			if got[i].Chars.Chars() != want[i].tokens[0] {
+				t.Errorf("expected chars %s; got %s", want[i].tokens[0], got[i].Chars.Chars())
+			}
+		}
+	}
+}
+
+func TestLineWords(t *testing.T) {
+	got := LineWords(" Die  Verfaſſung, des ")
+	want := []string{"Die", "Verfaſſung,", "des"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v; got %v", want, got)
+	}
+}
+
+func TestUpdateGlyphs(t *testing.T) {
+	for _, tc := range []struct {
+		text, want, boxes string
+	}{
+		{"abc", "a|b|c", "0-10|10-20|20-30"},
+		{"abd", "a|b|d*", "0-10|10-20|20-30"},
+		{"ac", "a|c", "0-10|20-30"},
+		{"xabc", "x*|a|b|c", "0-5|5-10|10-20|20-30"},
+		{"xyabc", "x*|y*|a|b|c", "0-3|3-6|6-10|10-20|20-30"},
+		{"abxc", "a|b|x*|c", "0-10|10-15|15-20|20-30"},
+		{"abcx", "a|b|c|x*", "0-10|10-20|20-25|25-30"},
+		{"", "", ""},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader(`<Word id="w">` +
+				`<Glyph id="g1"><Coords points="0,0 9,0 9,9 0,9"/><TextEquiv index="1"><Unicode>a</Unicode></TextEquiv></Glyph>` +
+				`<Glyph id="g2"><Coords points="10,0 19,0 19,9 10,9"/><TextEquiv index="1"><Unicode>b</Unicode></TextEquiv></Glyph>` +
+				`<Glyph id="g3"><Coords points="20,0 29,0 29,9 20,9"/><TextEquiv index="1"><Unicode>c</Unicode></TextEquiv></Glyph>` +
+				`<TextEquiv index="1"><Unicode>abc</Unicode></TextEquiv></Word>`))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			word := xmlquery.FindOne(doc, "/Word")
+			UpdateGlyphs(word, tc.text, nil, xml.Attr{Name: xml.Name{Local: "dataType"}, Value: "test"})
+			var glyphs, boxes []string
+			for _, glyph := range xmlquery.Find(word, "./Glyph") {
+				unicodes := FindUnicodesInRegionSorted(glyph)
+				box, ok := BoundingBox(glyph)
+				if len(unicodes) != 1 || !ok || box.Min.Y != 0 || box.Max.Y != 10 {
+					t.Fatalf("invalid glyph: %s", glyph.OutputXML(true))
+				}
+				str := unicodes[0].InnerText()
+				if unicodes[0].Parent.SelectAttr("dataType") == "test" {
+					str += "*"
+				}
+				glyphs = append(glyphs, str)
+				boxes = append(boxes, fmt.Sprintf("%d-%d", box.Min.X, box.Max.X))
+			}
+			if got := strings.Join(glyphs, "|"); got != tc.want {
+				t.Errorf("expected %q; got %q", tc.want, got)
+			}
+			if got := strings.Join(boxes, "|"); got != tc.boxes {
+				t.Errorf("expected boxes %q; got %q", tc.boxes, got)
+			}
+			if got := len(xmlquery.Find(word, "./TextEquiv")); got != 1 {
+				t.Errorf("expected 1 word TextEquiv; got %d", got)
+			}
+		})
+	}
+}
+
+func TestUpdateGlyphsIDs(t *testing.T) {
+	doc, err := xmlquery.Parse(strings.NewReader(`<Line><Word id="w">` +
+		`<Glyph id="w_glyph1"><Coords points="0,0 9,0 9,9 0,9"/><TextEquiv index="1"><Unicode>a</Unicode></TextEquiv></Glyph>` +
+		`<Glyph id="g2"><Coords points="10,0 19,0 19,9 10,9"/><TextEquiv index="1"><Unicode>b</Unicode></TextEquiv></Glyph>` +
+		`<TextEquiv index="1"><Unicode>ab</Unicode></TextEquiv></Word>` +
+		`<Word id="w_glyph2"/></Line>`))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	word := xmlquery.FindOne(doc, "//Word[@id='w']")
+	// Insert new glyphs twice (e.g. correct and a later apply).
+	UpdateGlyphs(word, "axb", nil)
+	UpdateGlyphs(word, "axyb", nil)
+	var got []string
+	for _, glyph := range xmlquery.Find(word, "./Glyph") {
+		got = append(got, glyph.SelectAttr("id"))
+	}
+	want := []string{"w_glyph1", "w_glyph3", "w_glyph4", "g2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected ids %v; got %v", want, got)
+	}
+}
+
+func TestUpdateGlyphsKeep(t *testing.T) {
+	doc, err := xmlquery.Parse(strings.NewReader(`<Word id="w">` +
+		`<Glyph id="g1"><Coords points="0,0 9,0 9,9 0,9"/>` +
+		`<TextEquiv index="1"><Unicode>a</Unicode></TextEquiv>` +
+		`<TextEquiv index="2"><Unicode>o</Unicode></TextEquiv></Glyph>` +
+		`<TextEquiv index="1"><Unicode>a</Unicode></TextEquiv></Word>`))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	word := xmlquery.FindOne(doc, "/Word")
+	var kept []string
+	UpdateGlyphs(word, "x", func(unicodes []*xmlquery.Node) {
+		for i, u := range unicodes {
+			kept = append(kept, u.InnerText())
+			node.SetAttr(u.Parent, xml.Attr{Name: xml.Name{Local: "index"}, Value: strconv.Itoa(i + 2)})
+		}
+	})
+	if want := []string{"a", "o"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("expected kept %v; got %v", want, kept)
+	}
+	var got []string
+	for _, u := range FindUnicodesInRegionSorted(xmlquery.FindOne(word, "./Glyph")) {
+		got = append(got, u.Parent.SelectAttr("index")+":"+u.InnerText())
+	}
+	if want := []string{"1:x", "2:a", "3:o"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	for _, tc := range []struct {
+		xml  string
+		want image.Rectangle
+		ok   bool
+	}{
+		{`<TextLine><Coords points="10,20 30,20 30,40 10,40"/></TextLine>`, image.Rect(10, 20, 31, 41), true},
+		{`<TextLine><Coords points="5,5"/></TextLine>`, image.Rect(5, 5, 6, 6), true},
+		{`<TextLine><Coords points=""/></TextLine>`, image.Rectangle{}, false},
+		{`<TextLine><Coords points="a,b"/></TextLine>`, image.Rectangle{}, false},
+		{`<TextLine/>`, image.Rectangle{}, false},
+	} {
+		t.Run(tc.xml, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader(tc.xml))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			got, ok := BoundingBox(xmlquery.FindOne(doc, "/TextLine"))
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("expected %v (%t); got %v (%t)", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestSplitBoundingBox(t *testing.T) {
+	for _, tc := range []struct {
+		r       image.Rectangle
+		weights []int
+		want    []image.Rectangle
+	}{
+		{image.Rect(0, 0, 10, 5), []int{1}, []image.Rectangle{image.Rect(0, 0, 10, 5)}},
+		{image.Rect(0, 0, 10, 5), []int{1, 1}, []image.Rectangle{image.Rect(0, 0, 5, 5), image.Rect(5, 0, 10, 5)}},
+		{image.Rect(10, 0, 20, 5), []int{3, 1, 1}, []image.Rectangle{
+			image.Rect(10, 0, 16, 5), image.Rect(16, 0, 18, 5), image.Rect(18, 0, 20, 5)}},
+		{image.Rect(0, 0, 4, 5), []int{0, 0}, []image.Rectangle{image.Rect(0, 0, 2, 5), image.Rect(2, 0, 4, 5)}},
+		{image.Rect(5, 5, 6, 6), []int{1, 1}, []image.Rectangle{image.Rect(5, 5, 6, 6), image.Rect(5, 5, 6, 6)}},
+	} {
+		t.Run(fmt.Sprintf("%v%v", tc.r, tc.weights), func(t *testing.T) {
+			if got := SplitBoundingBox(tc.r, tc.weights...); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetCoords(t *testing.T) {
+	for _, tc := range []string{
+		`<Word/>`,
+		`<Word><Coords points="1,1"/><TextEquiv/></Word>`,
+		`<Word><TextEquiv/></Word>`,
+	} {
+		t.Run(tc, func(t *testing.T) {
+			doc, err := xmlquery.Parse(strings.NewReader(tc))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			word := xmlquery.FindOne(doc, "/Word")
+			want := image.Rect(10, 20, 31, 41)
+			SetCoords(word, want)
+			if got := len(xmlquery.Find(word, "./Coords")); got != 1 {
+				t.Fatalf("expected 1 Coords; got %d", got)
+			}
+			if word.FirstChild.Data != "Coords" {
+				t.Errorf("expected Coords as first child; got %s", word.FirstChild.Data)
+			}
+			if got, ok := BoundingBox(word); !ok || got != want {
+				t.Errorf("expected %v; got %v (%t)", want, got, ok)
+			}
+		})
+	}
+}
@@ -27,8 +27,8 @@ type Extensions []string
 // Tokenize is a helper function that combines ReadLines and
 // TokenizeLines into one function.  It is the same as calling
 // `apoco.Pipe(ReadLines, TokenizeLines,...)`.
-func (e Extensions) Tokenize(ctx context.Context, lev bool, dirs ...string) apoco.StreamFunc {
-	return apoco.Combine(ctx, e.ReadLines(dirs...), e.TokenizeLines(lev))
+func (e Extensions) Tokenize(ctx context.Context, mode align.Mode, dirs ...string) apoco.StreamFunc {
+	return apoco.Combine(ctx, e.ReadLines(dirs...), e.TokenizeLines(mode))
 }
 
 // ReadLines returns a stream function that reads snippet files in
@@ -184,16 +184,12 @@ func makeTokensFromPairs(lines []apoco.Chars) []string {
 }
 
 // TokenizeLines returns a stream function that tokenizes
-// and aligns line tokens.
-func (e Extensions) TokenizeLines(alev bool) apoco.StreamFunc {
+// and aligns line tokens using the given alignment mode.
+func (e Extensions) TokenizeLines(mode align.Mode) apoco.StreamFunc {
 	return func(ctx context.Context, in <-chan apoco.T, out chan<- apoco.T) error {
-		var matrix lev.Mat
-		mat := &matrix
-		if !alev {
-			mat = nil
-		}
+		var mat lev.Mat
 		return apoco.EachToken(ctx, in, func(line apoco.T) error {
-			alignments := alignLines(mat, line.Tokens...)
+			alignments := alignLines(mode, &mat, line.Tokens...)
 			var ts []apoco.T
 			for i := range alignments {
 				t := apoco.T{
@@ -237,15 +233,12 @@ func (e Extensions) TokenizeLines(alev bool) apoco.StreamFunc {
 	}
 }
 
-func alignLines(mat *lev.Mat, lines ...string) [][]align.Pos {
+func alignLines(mode align.Mode, mat *lev.Mat, lines ...string) [][]align.Pos {
 	rs := make([][]rune, len(lines))
 	for i := range lines {
 		rs[i] = []rune(lines[i])
 	}
-	if mat != nil {
-		return align.Lev(mat, rs[0], rs[1:]...)
-	}
-	return align.Do(rs[0], rs[1:]...)
+	return mode.Align(mat, rs[0], rs[1:]...)
 }
 
 func readSnippetFile(path string) (apoco.Chars, error) {
@@ -8,7 +8,9 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"git.sr.ht/~flobar/apoco/pkg/apoco/align"
 	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"git.sr.ht/~flobar/lev"
 	"github.com/finkf/gofiler"
 	"golang.org/x/sync/errgroup"
 	"gonum.org/v1/gonum/mat"
@@ -215,27 +217,14 @@ func SendTokens(ctx context.Context, out chan<- T, tokens ...T) error {
 // Normalize returns a stream function that trims all leading and
 // subsequent punctionation from the tokens, converts them to
 // lowercase and replaces any whitespace (in the case of merges due to
-// alignment) with a '_'.
+// alignment) with a '_'.  The parts of joined hyphenated tokens are
+// normalized as well.
 func Normalize() StreamFunc {
 	return func(ctx context.Context, in <-chan T, out chan<- T) error {
 		err := EachToken(ctx, in, func(t T) error {
-			for i := range t.Tokens {
-				if i == 0 { // handle master OCR in a special way
-					t.Chars = normalizeChars(t.Chars)
-				}
-				t.Tokens[i] = strings.TrimFunc(t.Tokens[i], func(r rune) bool {
-					return unicode.IsPunct(r) || unicode.IsSpace(r)
-				})
-				t.Tokens[i] = strings.ReplaceAll(
-					strings.ToLower(t.Tokens[i]), " ", "_")
-			}
-			// We need to handle end of line markers in a special way.  In
-			// order to make sure that they are not removed even if they are
-			// empty after normalization, we make them long
-			// enough to not be removed (end of line markers are relevant for
-			// mrg training, so a length of 1 is sufficient).
-			if t.EOL && t.Tokens[0] == "" {
-				t.Tokens[0] = "$"
+			normalize(&t)
+			for i := range t.Parts {
+				normalize(&t.Parts[i])
 			}
 			if err := SendTokens(ctx, out, t); err != nil {
 				return fmt.Errorf("normalize: %v", err)
@@ -249,6 +238,27 @@ func Normalize() StreamFunc {
 	}
 }
 
+func normalize(t *T) {
+	for i := range t.Tokens {
+		if i == 0 { // handle master OCR in a special way
+			t.Chars = normalizeChars(t.Chars)
+		}
+		t.Tokens[i] = strings.TrimFunc(t.Tokens[i], func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSpace(r)
+		})
+		t.Tokens[i] = strings.ReplaceAll(
+			strings.ToLower(t.Tokens[i]), " ", "_")
+	}
+	// We need to handle end of line markers in a special way.  In
+	// order to make sure that they are not removed even if they are
+	// empty after normalization, we make them long
+	// enough to not be removed (end of line markers are relevant for
+	// mrg training, so a length of 1 is sufficient).
+	if t.EOL && t.Tokens[0] == "" {
+		t.Tokens[0] = "$"
+	}
+}
+
 func normalizeChars(chars Chars) Chars {
 	var i, j int
 	for i = 0; i < len(chars); i++ {
@@ -296,6 +306,38 @@ func FilterShort(min int) StreamFunc {
 	})
 }
 
+// FilterBadAlignments returns a stream function that filters all
+// lines with an alignment agreement (see align.Score) less than min.
+// The agreement of a line is calculated using the first nocr aligned
+// tokens of the line.  Additional tokens (i.e. the ground-truth) are
+// ignored.
+func FilterBadAlignments(min float64, nocr int) StreamFunc {
+	return func(ctx context.Context, in <-chan T, out chan<- T) error {
+		var m lev.Mat
+		var tokens [][]string
+		err := EachLine(ctx, in, func(line []T) error {
+			tokens = tokens[0:0]
+			for _, t := range line {
+				if len(t.Tokens) > nocr {
+					tokens = append(tokens, t.Tokens[:nocr])
+				} else {
+					tokens = append(tokens, t.Tokens)
+				}
+			}
+			if score := align.RateTokens(&m, tokens); score.Agreement() < min {
+				Log("filter bad alignment: %s (%s): agreement %g",
+					line[0].File, line[0].ID, score.Agreement())
+				return nil
+			}
+			return SendTokens(ctx, out, line...)
+		})
+		if err != nil {
+			return fmt.Errorf("filter bad alignments: %v", err)
+		}
+		return nil
+	}
+}
+
 // FilterLexiconEntries returns a stream function that filters all
 // tokens that are lexicon entries from the stream.
 func FilterLexiconEntries() StreamFunc {
@@ -360,6 +402,80 @@ func ConnectCandidates() StreamFunc {
 	}
 }
 
+// OCRCandidateDict is used as the Dict marker of the synthetic
+// candidates that are generated from the support OCR tokens (see
+// ConnectOCRCandidates).
+const OCRCandidateDict = "apoco_ocr"
+
+// ConnectOCRCandidates returns a stream function that adds the
+// readings of the first n OCR tokens (and their profiler
+// interpretations if they exist) as additional candidates.  It must be
+// used after ConnectCandidates.  The synthetic candidates are marked
+// with the OCRCandidateDict marker.  Their distance is the Levenshtein
+// distance to the master OCR token.  Suggestions that already exist
+// in the candidate list are skipped.
+func ConnectOCRCandidates(n int) StreamFunc {
+	return func(ctx context.Context, in <-chan T, out chan<- T) error {
+		var lfid, ltid string // last file id and last token id
+		var tokens []T
+		err := EachToken(ctx, in, func(t T) error {
+			if t.File != lfid || t.ID != ltid {
+				if len(tokens) > 0 {
+					if err := SendTokens(ctx, out, connectOCRCandidates(n, tokens)...); err != nil {
+						return err
+					}
+					tokens = tokens[0:0]
+				}
+				lfid = t.File
+				ltid = t.ID
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("connect ocr candidates: %v", err)
+		}
+		if len(tokens) > 0 {
+			if err := SendTokens(ctx, out, connectOCRCandidates(n, tokens)...); err != nil {
+				return fmt.Errorf("connect ocr candidates: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+func connectOCRCandidates(n int, tokens []T) []T {
+	seen := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		seen[t.Payload.(*gofiler.Candidate).Suggestion] = true
+	}
+	t := tokens[0]
+	add := func(c gofiler.Candidate) {
+		if c.Suggestion == "" || seen[c.Suggestion] {
+			return
+		}
+		seen[c.Suggestion] = true
+		c.Dict = OCRCandidateDict
+		c.OCRPatterns = nil
+		c.Distance = lev.Distance(t.Tokens[0], c.Suggestion)
+		t.Payload = &c
+		tokens = append(tokens, t)
+	}
+	for i := 1; i < n && i < len(t.Tokens); i++ {
+		r := t.Tokens[i]
+		add(gofiler.Candidate{Suggestion: r, Modern: r})
+		if t.Document == nil {
+			continue
+		}
+		if interp, ok := t.Document.Profile[r]; ok {
+			for _, c := range interp.Candidates {
+				add(c)
+			}
+		}
+	}
+	return tokens
+}
+
 // AddShortTokensToProfile returns a stream function that adds fake
 // profiler interpretation for short tokens into the token's profile.
 // Short tokens are tokens with less than or equal to max unicode
@@ -6,6 +6,10 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+
+	"git.sr.ht/~flobar/apoco/pkg/apoco/ml"
+	"git.sr.ht/~flobar/lev"
+	"github.com/finkf/gofiler"
 )
 
 func sendtoks(ts ...T) StreamFunc {
@@ -137,6 +141,85 @@ func TestFilterShort(t *testing.T) {
 	}
 }
 
+func TestFilterBadAlignments(t *testing.T) {
+	eol := func(ts ...T) []T {
+		ts[len(ts)-1].EOL = true
+		return ts
+	}
+	for _, tc := range []struct {
+		test []T
+		want string
+	}{
+		{eol(mktoks("ab|ab", "cd|cd")...), "ab|ab cd|cd"},
+		{eol(mktoks("ab|xy", "cd|cd")...), "ab|xy cd|cd"},
+		{eol(mktoks("ab|xy", "cd|xy")...), ""},
+		{append(eol(mktoks("ab|", "cd|")...), eol(mktoks("ef|ef")...)...), "ef|ef"},
+		// The ground-truth is ignored.
+		{eol(mktoks("ab|ab|xy", "cd|cd|xy")...), "ab|ab|xy cd|cd|xy"},
+		{eol(mktoks("ab|xy|ab", "cd|xy|cd")...), ""},
+	} {
+		t.Run(fmttoks(tc.test...), func(t *testing.T) {
+			var got []T
+			err := Pipe(context.Background(),
+				sendtoks(tc.test...), FilterBadAlignments(.5, 2), readtoks(&got))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if got := fmttoks(got...); got != tc.want {
+				t.Fatalf("expected %s; got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConnectOCRCandidates(t *testing.T) {
+	doc := &Document{Profile: gofiler.Profile{
+		"abc": gofiler.Interpretation{Candidates: []gofiler.Candidate{{Suggestion: "abc", Modern: "abc"}}},
+		"abx": gofiler.Interpretation{Candidates: []gofiler.Candidate{
+			{Suggestion: "aby", Modern: "aby", OCRPatterns: []gofiler.Pattern{{Left: "y", Right: "x", Pos: 2}}},
+		}},
+	}}
+	for _, tc := range []struct {
+		test string
+		want []string
+	}{
+		{"abc|abc|abc", []string{"abc"}},
+		{"abc|abd|abc", []string{"abc", "abd"}},
+		{"abc|abx|", []string{"abc", "abx", "aby"}},
+		{"abc|abd|abd|gt", []string{"abc", "abd"}},
+	} {
+		t.Run(tc.test, func(t *testing.T) {
+			tok := mktoks(tc.test)[0]
+			tok.Document = doc
+			tok.Payload = &doc.Profile["abc"].Candidates[0]
+			var got []T
+			err := Pipe(context.Background(),
+				sendtoks(tok), ConnectOCRCandidates(3), readtoks(&got))
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d candidates; got %d", len(tc.want), len(got))
+			}
+			for i := range got {
+				c := got[i].Payload.(*gofiler.Candidate)
+				if c.Suggestion != tc.want[i] {
+					t.Errorf("expected %s; got %s", tc.want[i], c.Suggestion)
+				}
+				if (c.Dict == OCRCandidateDict) != (i > 0) {
+					t.Errorf("bad dict marker for %s: %q", c.Suggestion, c.Dict)
+				}
+				if i > 0 && (len(c.OCRPatterns) != 0 || c.Distance != lev.Distance("abc", c.Suggestion)) {
+					t.Errorf("bad synthetic candidate: %+v", c)
+				}
+				if is, _ := CandidateIsOCRCandidate(got[i], 0, 3); is != ml.Bool(i > 0) {
+					t.Errorf("bad feature value for %s: %g", c.Suggestion, is)
+				}
+			}
+		})
+	}
+}
+
 func TestCombine(t *testing.T) {
 	for _, tc := range []struct {
 		test []T
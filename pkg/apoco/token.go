@@ -19,6 +19,7 @@ type T struct {
 	Tokens   []string    // Master and support OCRs and gt
 	EOL, SOL bool        // End of line and start of line marker.
 	IsSplit  bool        // Marks possible split tokens between the primary and secondary OCR.
+	Parts    []T         // Original tokens of joined hyphenated tokens (see JoinHyphenations).
 }
 
 // IsLexiconEntry returns true if this token is a normal lexicon entry
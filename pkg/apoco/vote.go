@@ -0,0 +1,118 @@
+package apoco
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finkf/gofiler"
+)
+
+// Voter implements a ROVER-style voting over the aligned OCR tokens.
+// The score of a word is calculated as
+// Alpha*freq + (1-Alpha)*conf (+ Lex if the word is a lexicon entry),
+// where freq is the relative number of OCRs voting for the word and
+// conf is the mean confidence of these OCRs.
+type Voter struct {
+	Alpha    float64 // Weight of the relative frequency of the votes.
+	NullConf float64 // Confidence of OCR tokens without character confidences.
+	Lex      float64 // Additional score for lexicon entries (requires a connected profile).
+}
+
+// Vote votes for the word of the first n (OCR) tokens of the given
+// token.  It returns the winning word and the confidence to use it
+// as correction of the master OCR token.  The confidence is calculated
+// as s/(s+m) where m is the score of the master OCR token and s is the
+// score of the winning word (or of the best other word if the master
+// OCR token wins).  Empty tokens do not vote.  Ties are resolved in
+// favour of the master OCR token.
+func (v Voter) Vote(t T, n int) (string, float64) {
+	if n > len(t.Tokens) {
+		n = len(t.Tokens)
+	}
+	type vote struct {
+		n, conf float64
+	}
+	votes := make(map[string]*vote, n)
+	var words []string // Keep the order of the votes.
+	for i := 0; i < n; i++ {
+		w := t.Tokens[i]
+		if w == "" {
+			continue
+		}
+		if _, ok := votes[w]; !ok {
+			votes[w] = &vote{}
+			words = append(words, w)
+		}
+		votes[w].n++
+		votes[w].conf += v.conf(t, i)
+	}
+	score := func(w string) float64 {
+		x, ok := votes[w]
+		if !ok {
+			return 0
+		}
+		ret := v.Alpha*x.n/float64(n) + (1-v.Alpha)*x.conf/x.n
+		if v.Lex != 0 && isLexiconWord(t.Document, w) {
+			ret += v.Lex
+		}
+		return ret
+	}
+	master := t.Tokens[0]
+	winner, other := master, ""
+	for _, w := range words {
+		if w == master {
+			continue
+		}
+		if other == "" || score(w) > score(other) {
+			other = w
+		}
+	}
+	if other != "" && score(other) > score(master) {
+		winner = other
+	}
+	if other == "" || score(other)+score(master) == 0 {
+		return winner, 0
+	}
+	return winner, score(other) / (score(other) + score(master))
+}
+
+// conf returns the mean character confidence of the master OCR token
+// or the null confidence for the other OCR tokens.
+func (v Voter) conf(t T, i int) float64 {
+	if i != 0 || len(t.Chars) == 0 {
+		return v.NullConf
+	}
+	var sum float64
+	for _, c := range t.Chars {
+		sum += c.Conf
+	}
+	return sum / float64(len(t.Chars))
+}
+
+func isLexiconWord(d *Document, w string) bool {
+	if d == nil {
+		return false
+	}
+	interp, ok := d.Profile[w]
+	return ok && len(interp.Candidates) == 1 && CandidateIsLexiconEntry(interp.Candidates[0])
+}
+
+// ConnectVotes returns a stream function that connects the tokens
+// with the voted correction of the first n OCR tokens (see
+// Voter.Vote).  The payload of the tokens is set to a Correction.
+func ConnectVotes(v Voter, n int) StreamFunc {
+	return func(ctx context.Context, in <-chan T, out chan<- T) error {
+		err := EachToken(ctx, in, func(t T) error {
+			w, conf := v.Vote(t, n)
+			t.Payload = Correction{
+				Candidate: &gofiler.Candidate{Suggestion: w, Modern: w},
+				Conf:      conf,
+			}
+			return SendTokens(ctx, out, t)
+		})
+		if err != nil {
+			return fmt.Errorf("connect votes: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package apoco
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/finkf/gofiler"
+)
+
+func TestVote(t *testing.T) {
+	profile := gofiler.Profile{
+		"abc": gofiler.Interpretation{Candidates: []gofiler.Candidate{{Suggestion: "abc", Modern: "abc"}}},
+	}
+	chars := func(str string, conf float64) Chars {
+		var ret Chars
+		for _, r := range str {
+			ret = append(ret, Char{Char: r, Conf: conf})
+		}
+		return ret
+	}
+	for _, tc := range []struct {
+		tokens []string
+		chars  Chars
+		voter  Voter
+		want   string
+		conf   float64
+	}{
+		{[]string{"abc"}, nil, Voter{Alpha: .5, NullConf: .5}, "abc", 0},
+		{[]string{"abc", "abc", "abc"}, nil, Voter{Alpha: .5, NullConf: .5}, "abc", 0},
+		{[]string{"abd", "abc", "abc"}, nil, Voter{Alpha: 1}, "abc", 2. / 3.},
+		{[]string{"abd", "abc", "abx"}, nil, Voter{Alpha: 1}, "abd", .5},
+		{[]string{"abd", "abc", ""}, nil, Voter{Alpha: 1}, "abd", .5},
+		{[]string{"abd", "abc", "abc"}, chars("abd", 1), Voter{Alpha: 0, NullConf: .5}, "abd", 1. / 3.},
+		{[]string{"abd", "abc"}, chars("abd", .2), Voter{Alpha: .5, NullConf: .6}, "abc", .55 / .9},
+		{[]string{"abd", "abc"}, nil, Voter{Alpha: 1, Lex: 1}, "abc", 1.5 / 2},
+		{[]string{"abd", "abc", "abc", "gt"}, nil, Voter{Alpha: 1}, "abc", 2. / 3.},
+	} {
+		t.Run(fmt.Sprintf("%v %+v", tc.tokens, tc.voter), func(t *testing.T) {
+			tok := T{Tokens: tc.tokens, Chars: tc.chars, Document: &Document{Profile: profile}}
+			n := len(tc.tokens)
+			if n == 4 { // last token is gt
+				n = 3
+			}
+			got, conf := tc.voter.Vote(tok, n)
+			if got != tc.want || fmt.Sprintf("%.4f", conf) != fmt.Sprintf("%.4f", tc.conf) {
+				t.Fatalf("expected %s (%g); got %s (%g)", tc.want, tc.conf, got, conf)
+			}
+		})
+	}
+}
+
+func TestConnectVotes(t *testing.T) {
+	var got []T
+	err := Pipe(context.Background(),
+		sendtoks(mktoks("a|b|b", "a|a|b")...),
+		ConnectVotes(Voter{Alpha: 1}, 3),
+		readtoks(&got))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := []string{"b", "a"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tokens; got %d", len(want), len(got))
+	}
+	for i := range got {
+		cor := got[i].Payload.(Correction)
+		if cor.Candidate.Suggestion != want[i] {
+			t.Errorf("expected %s; got %s", want[i], cor.Candidate.Suggestion)
+		}
+		if (cor.Conf > .5) != (want[i] != got[i].Tokens[0]) {
+			t.Errorf("bad confidence for %s: %g", got[i], cor.Conf)
+		}
+	}
+}